@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+var convertForce bool
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert <input> <output>",
+	Short: "Convert a config file between JSON, YAML and TOML",
+	Long: "Convert a config file between JSON, YAML and TOML. The formats are taken from the\n" +
+		"file extensions (.json, .yaml/.yml, .toml). Comments are not carried over.",
+	Example: "  igneos.cloud.cli config convert config.json config.yaml",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		input, output := args[0], args[1]
+
+		format, err := config.FormatOf(output)
+		if err != nil {
+			return err
+		}
+		data, err := config.ReadFile(input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+		content, err := config.Encode(data, format)
+		if err != nil {
+			return fmt.Errorf("could not encode %s: %w", output, err)
+		}
+
+		// The config contains credentials, keep it private
+		if err := writePrivateFile(output, content, convertForce); err != nil {
+			return err
+		}
+
+		fmt.Printf("Converted %s to %s\n", input, output)
+		return nil
+	},
+}
+
+func init() {
+	configConvertCmd.Flags().BoolVarP(&convertForce, "force", "f", false, "overwrite an existing output file")
+	configCmd.AddCommand(configConvertCmd)
+}
+
+// writePrivateFile writes a file readable by the owner only. An existing
+// file is only overwritten with force.
+func writePrivateFile(name string, content []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(name, flags, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", name)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
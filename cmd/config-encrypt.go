@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt <file>",
+	Short: "Encrypt the credentials of a config file in place",
+	Long: "Encrypt the credential fields (SSH, become and key passwords, nfs_pass, the registry\n" +
+		"password) of a config file in place with the vault key. Secret references and encrypted\n" +
+		"values are left as they are. Comments are not carried over.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rewriteConfig(args[0], func(data map[string]any) (string, error) {
+			n, err := config.EncryptSecrets(data)
+			return fmt.Sprintf("Encrypted %d values in %s", n, args[0]), err
+		})
+	},
+}
+
+var configDecryptCmd = &cobra.Command{
+	Use:   "decrypt <file>",
+	Short: "Decrypt all encrypted values of a config file in place",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rewriteConfig(args[0], func(data map[string]any) (string, error) {
+			return fmt.Sprintf("Decrypted %s", args[0]), config.DecryptValues(data)
+		})
+	},
+}
+
+// rewriteConfig applies change to a config file and writes it back in its format.
+func rewriteConfig(name string, change func(data map[string]any) (string, error)) error {
+	format, err := config.FormatOf(name)
+	if err != nil {
+		format = config.FormatJSON
+	}
+	data, err := config.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	msg, err := change(data)
+	if err != nil {
+		return err
+	}
+	content, err := config.Encode(data, format)
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", name, err)
+	}
+	if err := writePrivateFile(name, content, true); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, msg)
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configEncryptCmd, configDecryptCmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
+)
+
+var (
+	showEffective bool
+	showFormat    string
+)
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the merged config with secrets masked",
+	Long: "Print the config files chosen by --config or $" + configEnv + " merged into one, with\n" +
+		"plaintext secrets masked and relative paths resolved. With --effective the config of a\n" +
+		"run is printed instead: built-in defaults and the cluster profile are applied, secret\n" +
+		"references resolved and encrypted values decrypted, and every secret is masked.",
+	Example: "  igneos.cloud.cli --config base.json --config prod.json config show --effective\n" +
+		"  igneos.cloud.cli --config clusters.yaml --cluster prod config show --effective --format yaml",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths := configPaths()
+
+		format := showFormat
+		if format == "" {
+			var err error
+			if format, err = config.FormatOf(paths[0]); err != nil {
+				format = config.FormatJSON
+			}
+		}
+
+		var data map[string]any
+		var invalid error
+		if showEffective {
+			cfg, err := config.ReadConfig(paths, selectedCluster())
+			if err != nil {
+				return err
+			}
+			invalid = cfg.Validate()
+			cfg.MaskSecrets(redact.Mask)
+			if data, err = config.FromConfig(cfg); err != nil {
+				return err
+			}
+		} else {
+			var err error
+			if data, err = config.MergeFiles(paths); err != nil {
+				return err
+			}
+			config.MaskValues(data, redact.Mask)
+		}
+
+		content, err := config.Encode(data, format)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stdout.Write(content); err != nil {
+			return err
+		}
+		// Problems are reported after the config they refer to
+		return invalid
+	},
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&showEffective, "effective", false, "print the config with defaults, profile and secrets resolved")
+	configShowCmd.Flags().StringVar(&showFormat, "format", "", "output format json, yaml or toml (default the format of the first config file)")
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+var schemaOutput string
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check a config file and list every problem",
+	Long: "Check a config file and list every problem with the path of the field, one per line.\n" +
+		"Several files are merged in order as overlays. Without files the config chosen by\n" +
+		"--config or $" + configEnv + " is checked. Every cluster profile is checked unless one\n" +
+		"is selected with --cluster.",
+	Example: "  igneos.cloud.cli config validate config.yaml\n" +
+		"  igneos.cloud.cli config validate base.json prod.json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths := configPaths()
+		if len(args) > 0 {
+			paths = args
+		}
+
+		clusters := []string{selectedCluster()}
+		if clusters[0] == "" {
+			if data, err := config.MergeFiles(paths); err == nil && config.ClusterNames(data) != nil {
+				clusters = config.ClusterNames(data)
+			}
+		}
+
+		problems := 0
+		for _, cluster := range clusters {
+			label := configLabel(paths, cluster)
+			_, err := config.LoadConfig(paths, cluster)
+			var invalid *config.ValidationError
+			if errors.As(err, &invalid) {
+				for _, p := range invalid.Problems {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", label, p)
+				}
+				problems += len(invalid.Problems)
+				continue
+			}
+			if err != nil && len(clusters) > 1 {
+				// A broken profile does not hide the problems of the others
+				fmt.Fprintf(os.Stderr, "%s: %s\n", label, err)
+				problems++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", label, err)
+			}
+			fmt.Printf("%s is valid\n", label)
+		}
+		if problems > 0 {
+			return fmt.Errorf("%s has %d problems", configLabel(paths, ""), problems)
+		}
+		return nil
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the config file",
+	Long: "Print the JSON Schema of the config file for completion and checks in editors, e.g. with\n" +
+		"\"$schema\": \"./config.schema.json\" in JSON or\n" +
+		"# yaml-language-server: $schema=./config.schema.json in YAML.",
+	Example: "  igneos.cloud.cli config schema -o config.schema.json",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			return err
+		}
+		content = append(content, '\n')
+
+		if schemaOutput == "" {
+			_, err := os.Stdout.Write(content)
+			return err
+		}
+		return os.WriteFile(schemaOutput, content, 0644)
+	},
+}
+
+func init() {
+	configSchemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "", "write the schema to this file instead of stdout")
+	configCmd.AddCommand(configValidateCmd, configSchemaCmd)
+}
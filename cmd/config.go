@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
+	"igneos.cloud/kubernetes/k3s-installer/remote"
+	"igneos.cloud/kubernetes/k3s-installer/vault"
+)
+
+// configEnv names the environment variable with the config file path,
+// used when --config is not given. Several files are separated like in
+// $PATH, e.g. base.json:prod.json.
+const configEnv = "K3S_INSTALLER_CONFIG"
+
+// defaultConfigFile is used when neither --config nor K3S_INSTALLER_CONFIG is set.
+const defaultConfigFile = "config.json"
+
+// clusterEnv names the environment variable with the cluster profile,
+// used when --cluster is not given.
+const clusterEnv = "K3S_INSTALLER_CLUSTER"
+
+var (
+	configFiles []string
+	clusterName string
+)
+
+// appConfig is the configuration of this run, loaded on first use.
+var appConfig *config.AppConfig
+
+// configCmd groups the commands that work on config files.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with installer config files",
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringArrayVar(&configFiles, "config", nil,
+		fmt.Sprintf("config file in JSON, YAML or TOML, repeat to merge overlays in order (default $%s or %s)", configEnv, defaultConfigFile))
+	rootCmd.PersistentFlags().StringVar(&clusterName, "cluster", "",
+		fmt.Sprintf("cluster profile of the config file (default $%s)", clusterEnv))
+	rootCmd.AddCommand(configCmd)
+}
+
+// configPaths returns the config files chosen by --config, K3S_INSTALLER_CONFIG
+// or the default, in that order. Later files are overlays of earlier ones.
+func configPaths() []string {
+	if len(configFiles) > 0 {
+		return configFiles
+	}
+	var paths []string
+	for _, path := range filepath.SplitList(os.Getenv(configEnv)) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) > 0 {
+		return paths
+	}
+	return []string{defaultConfigFile}
+}
+
+// configPath returns the first config file, the base of all overlays.
+func configPath() string {
+	return configPaths()[0]
+}
+
+// selectedCluster returns the cluster profile chosen by --cluster, the menu
+// or K3S_INSTALLER_CLUSTER, empty if none.
+func selectedCluster() string {
+	if clusterName != "" {
+		return clusterName
+	}
+	return os.Getenv(clusterEnv)
+}
+
+// configClusters returns the cluster profiles of the config files, nil if
+// they have none or cannot be read.
+func configClusters() []string {
+	data, err := config.MergeFiles(configPaths())
+	if err != nil {
+		return nil
+	}
+	return config.ClusterNames(data)
+}
+
+// configLabel names the config files and the cluster profile in messages.
+func configLabel(paths []string, cluster string) string {
+	label := strings.Join(paths, " + ")
+	if cluster == "" {
+		return label
+	}
+	return fmt.Sprintf("%s [%s]", label, cluster)
+}
+
+// loadConfig loads and validates the configuration of the selected cluster
+// once per run, applies its SSH settings to the remote package and
+// registers its credentials and an existing k3s token for redaction.
+func loadConfig() (*config.AppConfig, error) {
+	if appConfig != nil {
+		return appConfig, nil
+	}
+
+	paths, cluster := configPaths(), selectedCluster()
+	cfg, err := config.LoadConfig(paths, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration %s: %w", configLabel(paths, cluster), err)
+	}
+
+	remote.Configure(cfg.SSH)
+	redact.Add(cfg.Secrets()...)
+	// An encrypted token is decrypted and registered when it is used
+	if token, err := os.ReadFile(cfg.K3sTokenFile); err == nil && !vault.IsEncrypted(token) {
+		redact.Add(strings.TrimSpace(string(token)))
+	}
+
+	appConfig = cfg
+	return cfg, nil
+}
@@ -1,25 +1,111 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"igneos.cloud/kubernetes/k3s-installer/config"
 	"igneos.cloud/kubernetes/k3s-installer/internal"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
+	"igneos.cloud/kubernetes/k3s-installer/remote"
+	"igneos.cloud/kubernetes/k3s-installer/utils"
+	"igneos.cloud/kubernetes/k3s-installer/vault"
+)
+
+var (
+	logDir  string
+	keyFile string
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "igneos.cloud.cli",
-	Short: "Igneos.Cloud K3s Cluster Management CLI",
-	Run: func(cmd *cobra.Command, args []string) {
-		startMenu()
+	Use:           "igneos.cloud.cli",
+	Short:         "Igneos.Cloud K3s Cluster Management CLI",
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		remote.SetTranscriptDir(logDir)
+		vault.SetKeyFile(keyFile)
 	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("the menu needs a terminal, use \"run <step>\" for non-interactive runs")
+		}
+		return startMenu(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logDir, "log-dir", "runs", "directory for per-host transcripts of every run, empty to disable")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "key-file", "",
+		fmt.Sprintf("age key for encrypted config values and artifacts (default $%s or ~/.config/k3s-installer/age.key)", vault.KeyFileEnv))
 }
 
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	ctx, cancel := interruptContext()
+	defer cancel(nil)
+
+	log.SetOutput(redact.NewWriter(os.Stderr))
+
+	err := redact.Error(rootCmd.ExecuteContext(ctx))
+	remote.CloseAll()
+	if dir := remote.TranscriptDir(); dir != "" {
+		fmt.Fprintln(os.Stderr, "Transcripts written to", dir)
+	}
+	remote.CloseTranscripts()
+	if errors.Is(context.Cause(ctx), remote.ErrInterrupted) {
+		fmt.Fprintln(os.Stderr, "Interrupted:", err)
+		os.Exit(130)
+	}
+	cobra.CheckErr(err)
+}
+
+// interruptContext returns a context that is cancelled with
+// remote.ErrInterrupted on the first SIGINT or SIGTERM, which aborts the
+// remote sessions on all nodes. A second signal exits immediately.
+func interruptContext() (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		fmt.Fprintln(os.Stderr, "\nInterrupting remote commands, press Ctrl-C again to exit immediately...")
+		cancel(remote.ErrInterrupted)
+		<-sigs
+		os.Exit(130)
+	}()
+	return ctx, cancel
+}
+
+// ----- Actions -----
+
+// action is one installer step, selectable in the menu and via "run <name>".
+type action struct {
+	name  string
+	label string
+	run   func(ctx context.Context, cfg *config.AppConfig) error
+	// component is the optional component installed by the action, if any
+	component string
+}
+
+var actions = []action{
+	{"full", "Install Full K3s-Cluster", installFullCluster, ""},
+	{"master", "Install Kubernetes Master", internal.InstallK3sMaster, ""},
+	{"worker", "Install Kubernetes Worker", internal.InstallK3sWorker, ""},
+	{"nfs-mount", "Create a NFS mount on worker", internal.MountNFS, config.ComponentNFS},
+	{"cert-manager", "Install Cert Manager", internal.InstallCertManager, config.ComponentCertManager},
+	{"nfs-provisioner", "Install NFS Provisioner", internal.InstallNFSSubdirExternalProvisioner, config.ComponentNFS},
+	{"registry", "Install Docker Registry", internal.InstallDockerRegistry, config.ComponentRegistry},
+	{"uninstall", "Uninstall Kubernetes FULL Cluster", internal.UninstallK3sCluster, ""},
 }
 
 // ----- Styling -----
@@ -29,27 +115,43 @@ var (
 	selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
 )
 
+// selectClusterLabel is the menu entry for choosing a cluster profile,
+// shown if the config file has profiles.
+const selectClusterLabel = "Select cluster"
+
 // ----- Model -----
 type model struct {
 	cursor int
 	choice string
 	items  []string
+	// cluster is the selected cluster profile shown above the items
+	cluster string
 }
 
 func initialModel() model {
+	var items []string
+	for _, a := range actions {
+		items = append(items, a.label)
+	}
+	if configClusters() != nil {
+		items = append(items, selectClusterLabel)
+	}
 	return model{
-		items: []string{
-			"Install Full K3s-Cluster",
-			"Install Kubernetes Master",
-			"Install Kubernetes Worker",
-			"Create a NFS mount on worker",
-			"Install Cert Manager",
-			"Install NFS Provisioner",
-			"Install Docker Registry",
-			"Uninstall Kubernetes FULL Cluster",
-			"Exit",
-		},
+		items:   append(items, wizardLabel, "Exit"),
+		cluster: selectedCluster(),
+	}
+}
+
+// clusterModel lists the cluster profiles of the config file with the
+// selected one under the cursor.
+func clusterModel(clusters []string) model {
+	m := model{items: clusters, cluster: selectedCluster()}
+	for i, name := range clusters {
+		if name == m.cluster {
+			m.cursor = i
+		}
 	}
+	return m
 }
 
 func (m model) Init() tea.Cmd {
@@ -86,6 +188,9 @@ func (m model) View() string {
 	IGNEOS.CLOUD K3s Cluster Installer (beta)
 	----------------------------------------------------------
 	`)
+	if m.cluster != "" {
+		s += fmt.Sprintf("\n  Cluster: %s\n", selectedStyle.Render(m.cluster))
+	}
 	s += "\n  Use ↑ ↓ to move, ↵ to select\n\n"
 
 	for i, item := range m.items {
@@ -101,50 +206,91 @@ func (m model) View() string {
 }
 
 // ----- Menüfunktion -----
-func startMenu() {
-	m := initialModel()
-	program := tea.NewProgram(m)
+func startMenu(ctx context.Context) error {
+	for {
+		choice, err := runMenu(initialModel())
+		if err != nil {
+			return err
+		}
+		if choice != selectClusterLabel {
+			return handleChoice(ctx, choice)
+		}
+
+		// Back to the menu with the chosen cluster, q keeps the previous one
+		cluster, err := runMenu(clusterModel(configClusters()))
+		if err != nil {
+			return err
+		}
+		if cluster != "" {
+			clusterName = cluster
+		}
+	}
+}
 
-	finalModel, err := program.Run()
+// runMenu shows a menu and returns the chosen item, empty if it was left
+// with q or ctrl+c.
+func runMenu(m model) (string, error) {
+	finalModel, err := tea.NewProgram(m).Run()
 	if err != nil {
-		fmt.Println("Error running menu:", err)
-		os.Exit(1)
-	}
-
-	if chosenModel, ok := finalModel.(model); ok {
-		handleChoice(chosenModel.choice)
-	}
-}
-
-func handleChoice(choice string) {
-	switch choice {
-	case "Install Kubernetes Master":
-		internal.InstallK3sMaster()
-	case "Install Kubernetes Worker":
-		internal.InstallK3sWorker()
-	case "Create a NFS mount on worker":
-		internal.MountNFS()
-	case "Install Cert Manager":
-		internal.InstallCertManager()
-	case "Install Full K3s-Cluster":
-		installFullCluster()
-	case "Install NFS Provisioner":
-		internal.InstallNFSSubdirExternalProvisioner()
-	case "Install Docker Registry":
-		internal.InstallDockerRegistry()
-	case "Uninstall Kubernetes FULL Cluster":
-		internal.UninstallK3sCluster()
-	case "Exit":
+		return "", fmt.Errorf("error running menu: %w", err)
+	}
+	chosenModel, _ := finalModel.(model)
+	return chosenModel.choice, nil
+}
+
+func handleChoice(ctx context.Context, choice string) error {
+	if choice == "Exit" {
 		fmt.Println("Goodbye!")
-		os.Exit(0)
+		return nil
+	}
+	if choice == wizardLabel {
+		return runWizard(ctx, configPath(), false)
+	}
+	for _, a := range actions {
+		if a.label == choice {
+			return runAction(ctx, a)
+		}
 	}
+	return nil
 }
 
-func installFullCluster() {
-	fmt.Println("\nInstalling full K3s Cluster with all components...")
-	internal.InstallK3sMaster()
-	internal.InstallK3sWorker()
-	internal.MountNFS()
-	internal.InstallCertManager()
-	internal.InstallNFSSubdirExternalProvisioner()
+// runAction loads the configuration, runs an installer step and names it
+// in the error if it fails.
+func runAction(ctx context.Context, a action) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if a.component != "" && !cfg.Enabled(a.component) {
+		return fmt.Errorf("%s: %s is disabled in the config (%s.enabled: false)", a.name, a.component, a.component)
+	}
+	if err := a.run(ctx, cfg); err != nil {
+		return fmt.Errorf("%s: %w", a.name, err)
+	}
+	return nil
+}
+
+// installFullCluster installs k3s and every enabled component. The
+// registry comes last, as it needs the NFS storage class and cert-manager.
+func installFullCluster(ctx context.Context, cfg *config.AppConfig) error {
+	fmt.Println("\nInstalling full K3s Cluster with all enabled components...")
+	steps := []action{
+		{"master", "", internal.InstallK3sMaster, ""},
+		{"worker", "", internal.InstallK3sWorker, ""},
+		{"nfs-mount", "", internal.MountNFS, config.ComponentNFS},
+		{"cert-manager", "", internal.InstallCertManager, config.ComponentCertManager},
+		{"nfs-provisioner", "", internal.InstallNFSSubdirExternalProvisioner, config.ComponentNFS},
+		{"registry", "", internal.InstallDockerRegistry, config.ComponentRegistry},
+	}
+	for _, step := range steps {
+		if step.component != "" && !cfg.Enabled(step.component) {
+			msg := fmt.Sprintf("Skipping %s, %s is disabled", step.name, step.component)
+			utils.PrintSectionHeader(msg, "[INFO]", utils.ColorBlue, false)
+			continue
+		}
+		if err := step.run(ctx, cfg); err != nil {
+			return fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+	return nil
 }
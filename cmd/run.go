@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/internal"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <step>",
+	Short: "Run a single installer step without the interactive menu",
+	Long: "Run a single installer step without the interactive menu, e.g. in CI jobs.\n" +
+		"Remote commands run without a PTY and the exit code reflects the result.\n\n" +
+		"Steps: " + strings.Join(actionNames(), ", "),
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: actionNames(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, a := range actions {
+			if a.name == args[0] {
+				return runAction(cmd.Context(), a)
+			}
+		}
+		return fmt.Errorf("unknown step %q, expected one of: %s", args[0], strings.Join(actionNames(), ", "))
+	},
+}
+
+func init() {
+	runCmd.Flags().BoolVarP(&internal.AssumeYes, "yes", "y", false, "answer all confirmation prompts with yes")
+	rootCmd.AddCommand(runCmd)
+}
+
+// actionNames lists the step names accepted by "run".
+func actionNames() []string {
+	var names []string
+	for _, a := range actions {
+		names = append(names, a.name)
+	}
+	return names
+}
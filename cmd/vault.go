@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"igneos.cloud/kubernetes/k3s-installer/vault"
+)
+
+var (
+	decryptOutput string
+	decryptForce  bool
+)
+
+// vaultCmd groups the commands that manage the age key and encrypted artifacts.
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Encrypt and decrypt config values, the node token and the kubeconfig",
+	Long: "Encrypt and decrypt with age. The key is the file given by --key-file or $" + vault.KeyFileEnv + ",\n" +
+		"otherwise the passphrase in $" + vault.PassphraseEnv + ", otherwise ~/.config/k3s-installer/age.key\n" +
+		"if it exists, otherwise a passphrase read from the terminal.",
+}
+
+var vaultKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a new age key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := vault.KeyFile()
+		recipient, err := vault.GenerateKey(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Generated age key %s\nPublic key: %s\nKeep a backup of it, encrypted values cannot be recovered without it.\n", path, recipient)
+		return nil
+	},
+}
+
+var vaultEncryptCmd = &cobra.Command{
+	Use:   "encrypt [file...]",
+	Short: "Encrypt files in place, or a value read from stdin",
+	Long: "Encrypt files in place, e.g. the k3s token file or a kubeconfig. Without files a single\n" +
+		"value is read from stdin and printed as \"enc:...\" for use in the config.",
+	Example: "  igneos.cloud.cli vault encrypt master-node-token\n" +
+		"  igneos.cloud.cli vault encrypt    # prompts for a value to put into the config",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			value, err := readValue()
+			if err != nil {
+				return err
+			}
+			enc, err := vault.EncryptValue(value)
+			if err != nil {
+				return err
+			}
+			fmt.Println(enc)
+			return nil
+		}
+
+		for _, name := range args {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			if vault.IsEncrypted(content) {
+				fmt.Printf("%s is already encrypted\n", name)
+				continue
+			}
+			if err := vault.WriteFile(name, content, true); err != nil {
+				return err
+			}
+			fmt.Printf("Encrypted %s\n", name)
+		}
+		return nil
+	},
+}
+
+var vaultDecryptCmd = &cobra.Command{
+	Use:   "decrypt <file|enc:value>",
+	Short: "Decrypt a file or an encrypted config value",
+	Long:  "Decrypt a file or an \"enc:...\" config value and print it, or write it to --output.",
+	Example: "  igneos.cloud.cli vault decrypt ~/.kube/config.age -o ~/.kube/config\n" +
+		"  igneos.cloud.cli vault decrypt master-node-token",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var plain []byte
+		if vault.IsEncryptedValue(args[0]) {
+			value, err := vault.DecryptValue(args[0])
+			if err != nil {
+				return err
+			}
+			plain = []byte(value + "\n")
+		} else {
+			content, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			if !vault.IsEncrypted(content) {
+				return fmt.Errorf("%s is not encrypted", args[0])
+			}
+			if plain, err = vault.Decrypt(content); err != nil {
+				return fmt.Errorf("could not decrypt %s: %w", args[0], err)
+			}
+		}
+
+		if decryptOutput == "" {
+			_, err := os.Stdout.Write(plain)
+			return err
+		}
+		return writePrivateFile(decryptOutput, plain, decryptForce)
+	},
+}
+
+// readValue reads a value to encrypt from the terminal without echo, or
+// the first line of stdin.
+func readValue() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Value: ")
+		value, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func init() {
+	vaultDecryptCmd.Flags().StringVarP(&decryptOutput, "output", "o", "", "write the plaintext to this file instead of stdout")
+	vaultDecryptCmd.Flags().BoolVarP(&decryptForce, "force", "f", false, "overwrite an existing output file")
+	vaultCmd.AddCommand(vaultKeygenCmd, vaultEncryptCmd, vaultDecryptCmd)
+	rootCmd.AddCommand(vaultCmd)
+}
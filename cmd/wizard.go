@@ -0,0 +1,711 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
+	"igneos.cloud/kubernetes/k3s-installer/remote"
+)
+
+// wizardLabel is the menu entry of the config wizard.
+const wizardLabel = "Create config file (wizard)"
+
+// sshTestTimeout bounds the connection test of one node.
+const sshTestTimeout = 20 * time.Second
+
+var (
+	wizardOutput string
+	wizardForce  bool
+)
+
+var configWizardCmd = &cobra.Command{
+	Use:   "wizard",
+	Short: "Create a config file interactively",
+	Long: "Ask for masters, workers, credentials, NFS, cert-manager and registry settings, check\n" +
+		"every answer, optionally test the SSH connection to each node and write a valid config.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output := wizardOutput
+		if output == "" {
+			output = configPath()
+		}
+		return runWizard(cmd.Context(), output, wizardForce)
+	},
+}
+
+func init() {
+	configWizardCmd.Flags().StringVarP(&wizardOutput, "output", "o", "", "default file to write (default the --config file)")
+	configWizardCmd.Flags().BoolVarP(&wizardForce, "force", "f", false, "allow overwriting an existing file")
+	configCmd.AddCommand(configWizardCmd)
+}
+
+// ----- Questions -----
+
+// wizardQuestion is one prompt of the wizard. A question with expand is a
+// placeholder that is replaced by the questions it returns once it is
+// reached, so they can depend on earlier answers.
+type wizardQuestion struct {
+	prompt   string
+	def      string // taken for an empty answer
+	secret   bool
+	yesNo    bool
+	optional bool // an empty answer without default is allowed
+	testSSH  bool // a yes runs the connection test
+	validate func(answer string) error
+	apply    func(w *wizardState, answer string)
+	expand   func(w *wizardState) []wizardQuestion
+}
+
+// wizardState is the config built from the answers given so far.
+type wizardState struct {
+	cfg     config.AppConfig
+	nfsNode config.NodeConfig
+	output  string
+	force   bool
+	queue   []wizardQuestion
+}
+
+func newWizardState(output string, force bool) *wizardState {
+	w := &wizardState{output: output, force: force}
+	w.queue = wizardQuestions()
+	return w
+}
+
+// next returns the current question, or nil when all are answered.
+func (w *wizardState) next() *wizardQuestion {
+	for len(w.queue) > 0 && w.queue[0].expand != nil {
+		expand := w.queue[0].expand
+		w.queue = append(expand(w), w.queue[1:]...)
+	}
+	if len(w.queue) == 0 {
+		return nil
+	}
+	return &w.queue[0]
+}
+
+// answer applies the answer to the current question.
+func (w *wizardState) answer(a string) {
+	q := w.next()
+	w.queue = w.queue[1:]
+	if q.apply != nil {
+		q.apply(w, a)
+	}
+}
+
+// insert puts questions right after the current one.
+func (w *wizardState) insert(qs ...wizardQuestion) {
+	w.queue = append(qs, w.queue...)
+}
+
+// config returns the config built so far.
+func (w *wizardState) config() config.AppConfig {
+	cfg := w.cfg
+	if cfg.NFS.IsEnabled() {
+		cfg.NFS.SetNode(w.nfsNode)
+	}
+	return cfg
+}
+
+func yes(answer string) bool {
+	return answer == "y"
+}
+
+// disabled returns an Enabled flag set to false.
+func disabled() *bool {
+	b := false
+	return &b
+}
+
+// wizardQuestions returns the questions in order: cluster, masters,
+// workers, NFS, cert-manager, registry, SSH test and output file.
+func wizardQuestions() []wizardQuestion {
+	return []wizardQuestion{
+		{prompt: "Cluster domain (added to the API server certificate)", validate: config.ValidateDomain,
+			apply: func(w *wizardState, a string) { w.cfg.Domain = a }},
+		{prompt: "File for the k3s node token", def: config.DefaultTokenFile,
+			apply: func(w *wizardState, a string) { w.cfg.K3sTokenFile = a }},
+		{prompt: "SSH host key policy (strict or tofu)", def: config.HostKeyPolicyStrict,
+			validate: oneOf(config.HostKeyPolicyStrict, config.HostKeyPolicyTOFU),
+			apply:    func(w *wizardState, a string) { w.cfg.SSH.HostKeyPolicy = a }},
+		{expand: func(w *wizardState) []wizardQuestion { return nodeListQuestions(w, "master") }},
+		{prompt: "Add a worker node?", def: "y", yesNo: true, apply: func(w *wizardState, a string) {
+			if yes(a) {
+				w.insert(wizardQuestion{expand: func(w *wizardState) []wizardQuestion { return nodeListQuestions(w, "worker") }})
+			}
+		}},
+		{prompt: "Set up NFS storage (export on an NFS server and provisioner)?", def: "y", yesNo: true, apply: func(w *wizardState, a string) {
+			if !yes(a) {
+				w.cfg.NFS.Enabled = disabled()
+				return
+			}
+			w.insert(nfsQuestions(w)...)
+		}},
+		{prompt: "Install cert-manager with a Let's Encrypt issuer?", def: "y", yesNo: true, apply: func(w *wizardState, a string) {
+			if !yes(a) {
+				w.cfg.CertManager.Enabled = disabled()
+				return
+			}
+			w.insert(
+				wizardQuestion{prompt: "Email address for Let's Encrypt", validate: config.ValidateEmail,
+					apply: func(w *wizardState, a string) { w.cfg.Email = a }},
+				wizardQuestion{prompt: "Name of the cluster issuer", def: config.DefaultClusterIssuerName, validate: config.ValidateResourceName,
+					apply: func(w *wizardState, a string) { w.cfg.ClusterIssuerName = a }},
+			)
+		}},
+		{expand: registryQuestions},
+		{prompt: "Test the SSH connection to every node now?", def: "y", yesNo: true, testSSH: true},
+		{expand: func(w *wizardState) []wizardQuestion {
+			return []wizardQuestion{{prompt: "Write the config to", def: w.output,
+				validate: func(a string) error { return checkOutput(a, w.force) },
+				apply:    func(w *wizardState, a string) { w.output = a }}}
+		}},
+	}
+}
+
+// nodeListQuestions adds a master or worker and asks for its settings and
+// whether another one follows.
+func nodeListQuestions(w *wizardState, kind string) []wizardQuestion {
+	list := &w.cfg.Workers
+	if kind == "master" {
+		list = &w.cfg.Masters
+	}
+	*list = append(*list, config.NodeConfig{})
+	i := len(*list) - 1
+	node := func(w *wizardState) *config.NodeConfig {
+		if kind == "master" {
+			return &w.cfg.Masters[i]
+		}
+		return &w.cfg.Workers[i]
+	}
+
+	qs := nodeQuestions(fmt.Sprintf("%s %d", strings.ToUpper(kind[:1])+kind[1:], i+1), node)
+	return append(qs, wizardQuestion{prompt: "Add another " + kind + "?", def: "n", yesNo: true, apply: func(w *wizardState, a string) {
+		if yes(a) {
+			w.insert(wizardQuestion{expand: func(w *wizardState) []wizardQuestion { return nodeListQuestions(w, kind) }})
+		}
+	}})
+}
+
+// nodeQuestions asks for the address, login and privilege escalation of a
+// node. node returns the node being edited.
+func nodeQuestions(name string, node func(w *wizardState) *config.NodeConfig) []wizardQuestion {
+	return []wizardQuestion{
+		{prompt: name + ": IP address or hostname", validate: config.ValidateAddress,
+			apply: func(w *wizardState, a string) { node(w).IP = a }},
+		{prompt: name + ": SSH port", def: strconv.Itoa(config.DefaultSSHPort), validate: validatePortAnswer,
+			apply: func(w *wizardState, a string) {
+				if p, _ := strconv.Atoi(a); p != config.DefaultSSHPort {
+					node(w).Port = p
+				}
+			}},
+		{prompt: name + ": SSH user",
+			apply: func(w *wizardState, a string) { node(w).SSHUser = a }},
+		{prompt: name + ": authentication (key, password or agent)", def: "key", validate: validateAuthAnswer,
+			apply: func(w *wizardState, a string) {
+				switch a {
+				case "key":
+					w.insert(
+						wizardQuestion{prompt: name + ": private key file", def: "~/.ssh/id_ed25519", validate: validateKeyFile,
+							apply: func(w *wizardState, a string) { node(w).SSHKeyPath = a }},
+						wizardQuestion{prompt: name + ": key passphrase (empty if none)", secret: true, optional: true,
+							apply: func(w *wizardState, a string) { node(w).SSHKeyPassphrase = a }},
+					)
+				case "password":
+					w.insert(wizardQuestion{prompt: name + ": SSH password", secret: true,
+						apply: func(w *wizardState, a string) { node(w).SSHPass = a }})
+				case "agent":
+					node(w).SSHAgent = true
+				}
+			}},
+		{prompt: name + ": privilege escalation (sudo, doas, su or none)", def: config.BecomeSudo,
+			validate: oneOf(config.BecomeSudo, config.BecomeDoas, config.BecomeSu, config.BecomeNone),
+			apply: func(w *wizardState, a string) {
+				n := node(w)
+				if a != config.BecomeSudo {
+					n.Become = a
+				}
+				switch {
+				case a == config.BecomeSu:
+					w.insert(wizardQuestion{prompt: name + ": root password for su", secret: true,
+						apply: func(w *wizardState, a string) { node(w).BecomePass = a }})
+				case a != config.BecomeNone && n.SSHPass == "":
+					w.insert(wizardQuestion{prompt: name + ": " + a + " password (empty if not needed)", secret: true, optional: true,
+						apply: func(w *wizardState, a string) { node(w).BecomePass = a }})
+				}
+			}},
+	}
+}
+
+// nfsQuestions asks for the NFS server and the export.
+func nfsQuestions(w *wizardState) []wizardQuestion {
+	qs := nodeQuestions("NFS server", func(w *wizardState) *config.NodeConfig { return &w.nfsNode })
+	return append(qs,
+		wizardQuestion{prompt: "Client network allowed to mount the export (CIDR)", def: defaultCIDR(w.cfg.Masters), validate: config.ValidateCIDR,
+			apply: func(w *wizardState, a string) { w.cfg.NFS.NetworkCIDR = a }},
+		wizardQuestion{expand: func(w *wizardState) []wizardQuestion {
+			return []wizardQuestion{{prompt: "Address of the NFS server for the cluster", def: w.nfsNode.Host(), validate: config.ValidateAddress,
+				apply: func(w *wizardState, a string) { w.cfg.NFS.Server = a }}}
+		}},
+		wizardQuestion{prompt: "Export path", def: config.DefaultNFSExport, validate: config.ValidateExportPath,
+			apply: func(w *wizardState, a string) { w.cfg.NFS.Export = a }},
+		wizardQuestion{prompt: "Capacity of the export", def: config.DefaultNFSCapacity, validate: config.ValidateQuantity,
+			apply: func(w *wizardState, a string) { w.cfg.NFS.Capacity = a }},
+	)
+}
+
+// registryQuestions asks for the registry, which needs NFS for its volume
+// and cert-manager for TLS.
+func registryQuestions(w *wizardState) []wizardQuestion {
+	if !w.cfg.NFS.IsEnabled() {
+		w.cfg.DockerRegistry.Enabled = disabled()
+		return nil
+	}
+
+	details := func(w *wizardState) []wizardQuestion {
+		url := "registry." + w.cfg.Domain
+		if w.cfg.DockerRegistry.Local {
+			url = "registry.local"
+		}
+		return []wizardQuestion{
+			{prompt: "Registry host name", def: url, validate: config.ValidateDomain,
+				apply: func(w *wizardState, a string) { w.cfg.DockerRegistry.URL = a }},
+			{prompt: "Registry volume size", def: config.DefaultRegistryCapacity, validate: config.ValidateQuantity,
+				apply: func(w *wizardState, a string) { w.cfg.DockerRegistry.PVCStorageCapacity = a }},
+			{prompt: "Registry user", def: "registry",
+				apply: func(w *wizardState, a string) { w.cfg.DockerRegistry.User = a }},
+			{prompt: "Registry password", secret: true,
+				apply: func(w *wizardState, a string) { w.cfg.DockerRegistry.Pass = a }},
+		}
+	}
+
+	return []wizardQuestion{{prompt: "Install a Docker registry?", def: "n", yesNo: true, apply: func(w *wizardState, a string) {
+		if !yes(a) {
+			w.cfg.DockerRegistry.Enabled = disabled()
+			return
+		}
+		if !w.cfg.CertManager.IsEnabled() {
+			// Without cert-manager there is no certificate for TLS
+			w.cfg.DockerRegistry.Local = true
+			w.insert(wizardQuestion{expand: details})
+			return
+		}
+		w.insert(
+			wizardQuestion{prompt: "Serve the registry over HTTP without TLS (local)?", def: "n", yesNo: true,
+				apply: func(w *wizardState, a string) { w.cfg.DockerRegistry.Local = yes(a) }},
+			wizardQuestion{expand: details},
+		)
+	}}}
+}
+
+// defaultCIDR suggests the /24 network of the first master.
+func defaultCIDR(masters []config.NodeConfig) string {
+	if len(masters) == 0 {
+		return ""
+	}
+	ip := net.ParseIP(masters[0].Host()).To4()
+	if ip == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+}
+
+// ----- Answer checks -----
+
+func oneOf(values ...string) func(string) error {
+	return func(a string) error {
+		for _, v := range values {
+			if a == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+func validateAuthAnswer(a string) error {
+	if err := oneOf("key", "password", "agent")(a); err != nil {
+		return err
+	}
+	if a == "agent" && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return errors.New("SSH_AUTH_SOCK is not set, start an ssh-agent or choose another method")
+	}
+	return nil
+}
+
+func validatePortAnswer(a string) error {
+	p, err := strconv.Atoi(a)
+	if err != nil || p == 0 {
+		return fmt.Errorf("%q is not a port number", a)
+	}
+	return config.ValidatePort(p)
+}
+
+// validateKeyFile checks that a private key exists locally.
+func validateKeyFile(a string) error {
+	p := a
+	if rest, ok := strings.CutPrefix(a, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		p = filepath.Join(home, rest)
+	}
+	if _, err := os.Stat(p); err != nil {
+		return fmt.Errorf("cannot read %s: %w", a, errors.Unwrap(err))
+	}
+	return nil
+}
+
+// checkOutput checks the file the config is written to.
+func checkOutput(name string, force bool) error {
+	if _, err := config.FormatOf(name); err != nil {
+		return err
+	}
+	if _, err := os.Stat(name); err == nil && !force {
+		return fmt.Errorf("%s already exists, choose another file or use config wizard --force", name)
+	}
+	return nil
+}
+
+// ----- Model -----
+
+var (
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	dimStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	warnStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+)
+
+// historyLines is the number of answered questions shown above the prompt.
+const historyLines = 12
+
+type sshTestMsg struct {
+	results []string
+}
+
+type wizardModel struct {
+	ctx      context.Context
+	output   string
+	force    bool
+	state    *wizardState
+	answers  []string
+	history  []string
+	input    textinput.Model
+	err      error
+	testing  bool
+	testAt   int // number of answers before the SSH test, -1 if it did not run
+	results  []string
+	problems []string
+	done     bool
+}
+
+func newWizardModel(ctx context.Context, output string, force bool) wizardModel {
+	m := wizardModel{ctx: ctx, output: output, force: force, testAt: -1}
+	m.replay()
+	return m
+}
+
+// replay rebuilds the state from the answers, which is how going back works.
+func (m *wizardModel) replay() {
+	m.state = newWizardState(m.output, m.force)
+	m.history = nil
+	for _, a := range m.answers {
+		q := m.state.next()
+		m.history = append(m.history, historyLine(q, a))
+		m.state.answer(a)
+	}
+	m.resetInput()
+}
+
+func (m *wizardModel) resetInput() {
+	m.input = textinput.New()
+	m.input.Prompt = "› "
+	if q := m.state.next(); q != nil {
+		if q.secret {
+			m.input.EchoMode = textinput.EchoPassword
+			m.input.EchoCharacter = '•'
+		}
+	}
+	m.input.Focus()
+	m.err = nil
+}
+
+func historyLine(q *wizardQuestion, a string) string {
+	if q.secret && a != "" {
+		a = "••••••"
+	}
+	return fmt.Sprintf("✔ %s: %s", q.prompt, a)
+}
+
+// normalizeAnswer turns the input into the answer of q: the default for empty
+// input, "y" or "n" for yes/no questions.
+func normalizeAnswer(q *wizardQuestion, input string) (string, error) {
+	a := input
+	if !q.secret {
+		a = strings.TrimSpace(input)
+	}
+	if a == "" {
+		a = q.def
+	}
+	if q.yesNo {
+		switch strings.ToLower(a) {
+		case "y", "yes":
+			return "y", nil
+		case "n", "no":
+			return "n", nil
+		}
+		return "", errors.New("answer y or n")
+	}
+	if a == "" {
+		if q.optional {
+			return "", nil
+		}
+		return "", errors.New("an answer is required")
+	}
+	if q.validate != nil {
+		if err := q.validate(a); err != nil {
+			return "", err
+		}
+	}
+	return a, nil
+}
+
+func (m wizardModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case sshTestMsg:
+		m.testing = false
+		m.results = msg.results
+		m.testAt = len(m.answers)
+		return m.commit("y")
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if m.testing {
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc":
+			if len(m.answers) > 0 {
+				m.answers = m.answers[:len(m.answers)-1]
+				if len(m.answers) <= m.testAt {
+					m.results, m.testAt = nil, -1
+				}
+				m.problems = nil
+				m.replay()
+			}
+			return m, nil
+		case "enter":
+			q := m.state.next()
+			if q == nil {
+				return m, nil
+			}
+			a, err := normalizeAnswer(q, m.input.Value())
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			if q.testSSH && yes(a) {
+				m.testing = true
+				return m, testConnections(m.ctx, m.state.config())
+			}
+			return m.commit(a)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// commit records an answer and moves on. After the last answer the whole
+// config is validated; it is only written if there are no problems.
+func (m wizardModel) commit(a string) (tea.Model, tea.Cmd) {
+	m.history = append(m.history, historyLine(m.state.next(), a))
+	m.answers = append(m.answers, a)
+	m.state.answer(a)
+	m.resetInput()
+
+	if m.state.next() != nil {
+		return m, nil
+	}
+	cfg := m.state.config()
+	var invalid *config.ValidationError
+	if err := cfg.Validate(); errors.As(err, &invalid) {
+		for _, p := range invalid.Problems {
+			m.problems = append(m.problems, p.String())
+		}
+		return m, nil
+	} else if err != nil {
+		m.problems = []string{err.Error()}
+		return m, nil
+	}
+	m.done = true
+	return m, tea.Quit
+}
+
+func (m wizardModel) View() string {
+	s := titleStyle.Render(`
+	----------------------------------------------------------
+	IGNEOS.CLOUD K3s Cluster Installer - config wizard
+	----------------------------------------------------------
+	`)
+	s += "\n  ↵ to confirm, empty input takes the default, esc to go back, ctrl+c to abort\n\n"
+
+	history := m.history
+	if len(history) > historyLines {
+		history = history[len(history)-historyLines:]
+	}
+	for _, h := range history {
+		s += "  " + dimStyle.Render(h) + "\n"
+	}
+
+	for _, r := range m.results {
+		s += "  " + r + "\n"
+	}
+
+	if len(m.problems) > 0 {
+		s += "\n  " + errorStyle.Render("The config is not valid, press esc to change your answers:") + "\n"
+		for _, p := range m.problems {
+			s += "  " + errorStyle.Render("  "+p) + "\n"
+		}
+		return s
+	}
+	if m.testing {
+		return s + "\n  Testing SSH connections...\n"
+	}
+
+	q := m.state.next()
+	if q == nil {
+		return s
+	}
+	prompt := q.prompt
+	if q.yesNo {
+		prompt += " (y/n)"
+	}
+	if q.def != "" {
+		prompt += fmt.Sprintf(" [%s]", q.def)
+	}
+	s += "\n  " + selectedStyle.Render(prompt) + "\n  " + m.input.View() + "\n"
+	if m.err != nil {
+		s += "  " + errorStyle.Render(m.err.Error()) + "\n"
+	}
+	return s
+}
+
+// testConnections logs in to every node of cfg and runs "true".
+func testConnections(ctx context.Context, cfg config.AppConfig) tea.Cmd {
+	return func() tea.Msg {
+		remote.Configure(cfg.SSH)
+		redact.Add(cfg.Secrets()...)
+
+		type target struct {
+			name string
+			node config.NodeConfig
+		}
+		var targets []target
+		for i, n := range cfg.Masters {
+			targets = append(targets, target{fmt.Sprintf("Master %d", i+1), n})
+		}
+		for i, n := range cfg.Workers {
+			targets = append(targets, target{fmt.Sprintf("Worker %d", i+1), n})
+		}
+		if cfg.NFS.IsEnabled() {
+			targets = append(targets, target{"NFS server", cfg.NFS.Node()})
+		}
+
+		// Notices like a newly trusted host key are shown below the result
+		// of their node, printing them would garble the screen
+		lines := make([][]string, len(targets))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for i, t := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var notices []string
+				ctx := remote.WithNotices(ctx, func(msg string) {
+					mu.Lock()
+					defer mu.Unlock()
+					notices = append(notices, warnStyle.Render("  ! "+msg))
+				})
+				ctx, cancel := context.WithTimeout(ctx, sshTestTimeout)
+				defer cancel()
+				res, err := remote.Run(ctx, t.node, "true")
+				if err == nil {
+					err = res.Err()
+				}
+
+				result := okStyle.Render(fmt.Sprintf("✔ %s (%s): SSH connection works", t.name, t.node.IP))
+				if err != nil {
+					result = errorStyle.Render(fmt.Sprintf("✘ %s (%s): %s", t.name, t.node.IP, redact.String(err.Error())))
+				}
+				mu.Lock()
+				lines[i] = append([]string{result}, notices...)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		var results []string
+		for _, l := range lines {
+			results = append(results, l...)
+		}
+		return sshTestMsg{results}
+	}
+}
+
+// runWizard runs the config wizard and writes the config file.
+func runWizard(ctx context.Context, output string, force bool) error {
+	program := tea.NewProgram(newWizardModel(ctx, output, force))
+	finalModel, err := program.Run()
+	if err != nil {
+		return fmt.Errorf("error running config wizard: %w", err)
+	}
+	m, ok := finalModel.(wizardModel)
+	if !ok || !m.done {
+		fmt.Println("Config wizard aborted, nothing was written.")
+		return nil
+	}
+
+	cfg := m.state.config()
+	data, err := config.FromConfig(&cfg)
+	if err != nil {
+		return err
+	}
+	format, err := config.FormatOf(m.state.output)
+	if err != nil {
+		return err
+	}
+	content, err := config.Encode(data, format)
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", m.state.output, err)
+	}
+	// The config contains credentials, keep it private
+	if err := writePrivateFile(m.state.output, content, force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Config written to %s\n", m.state.output)
+	fmt.Println("Passwords are stored in plaintext, use \"config encrypt\" or secret references to protect them.")
+	return nil
+}
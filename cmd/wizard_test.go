@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// wizardAnswer answers the questions whose prompt contains key.
+type wizardAnswer struct {
+	key, answer string
+}
+
+// answerWizard answers the questions of a new wizard: with the first
+// answer whose key is part of the prompt, otherwise with the default.
+func answerWizard(t *testing.T, answers []wizardAnswer) *wizardState {
+	t.Helper()
+	w := newWizardState("config.json", false)
+	for q := w.next(); q != nil; q = w.next() {
+		a := ""
+		for _, wa := range answers {
+			if strings.Contains(q.prompt, wa.key) {
+				a = wa.answer
+				break
+			}
+		}
+		if q.testSSH {
+			a = "n"
+		}
+		if a == "" {
+			a = q.def
+		}
+		if a == "" && !q.optional {
+			t.Fatalf("no answer for %q", q.prompt)
+		}
+		if q.validate != nil {
+			if err := q.validate(a); err != nil {
+				t.Fatalf("answer %q to %q: %v", a, q.prompt, err)
+			}
+		}
+		w.answer(a)
+	}
+	return w
+}
+
+func TestWizardNFSServer(t *testing.T) {
+	answers := []wizardAnswer{
+		{"Cluster domain", "example.com"},
+		{"NFS server: IP address", "nas.example.com"},
+		{"IP address", "10.0.0.1"},
+		{"SSH user", "admin"},
+		{"authentication", "password"},
+		{"SSH password", "secret"},
+		{"Add a worker", "n"},
+		{"Add another", "n"},
+		{"Email address", "ops@example.com"},
+		{"Install a Docker", "n"},
+	}
+
+	tests := []struct {
+		mountAddress string // empty takes the default, the NFS server node
+		want         string
+	}{
+		{"", "nas.example.com"},
+		{"10.1.0.3", "10.1.0.3"},
+	}
+	for _, tt := range tests {
+		cfg := answerWizard(t, append(answers, wizardAnswer{"Address of the NFS server", tt.mountAddress})).config()
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("wizard config is not valid: %v", err)
+		}
+		// The NFS provisioner mounts the export from MountServer
+		if got := cfg.NFS.MountServer(); got != tt.want {
+			t.Errorf("mount address %q: provisioner uses %q, want %q", tt.mountAddress, got, tt.want)
+		}
+	}
+}
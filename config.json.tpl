@@ -18,10 +18,11 @@
     "pvc_storagy_capacity":"10Gi",
     "pass": "123456",
     "user": "registry",
-    local: false
+    "local": false
   },
   "k3s_token_file": "master-node-token",
   "nfs": {
+    "network_CIDR": "10.0.0.0/24",
     "nfs_server": "",
     "nfs_user": "",
     "nfs_pass": "",
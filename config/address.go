@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultSSHPort is used for nodes without an explicit port.
+const DefaultSSHPort = 22
+
+// Host returns the node address without IPv6 brackets, e.g. "fd00::10" for "[fd00::10]".
+func (n NodeConfig) Host() string {
+	return strings.TrimSuffix(strings.TrimPrefix(n.IP, "["), "]")
+}
+
+// URLHost returns the node address for use in URLs, IPv6 literals are bracketed.
+func (n NodeConfig) URLHost() string {
+	host := n.Host()
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// SSHPort returns the configured port or DefaultSSHPort.
+func (n NodeConfig) SSHPort() int {
+	if n.Port == 0 {
+		return DefaultSSHPort
+	}
+	return n.Port
+}
+
+// Address returns "host:port" for dialing, e.g. "10.0.0.1:22" or "[fd00::10]:2222".
+func (n NodeConfig) Address() string {
+	return net.JoinHostPort(n.Host(), strconv.Itoa(n.SSHPort()))
+}
+
+// validateAddress checks that host is an IPv4 address, an IPv6 address
+// (optionally in brackets) or a valid DNS hostname.
+func validateAddress(host string) error {
+	if host == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if strings.HasPrefix(host, "[") || strings.HasSuffix(host, "]") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if ip := net.ParseIP(inner); ip == nil || ip.To4() != nil || len(inner)+2 != len(host) {
+			return fmt.Errorf("%q is not a valid bracketed IPv6 address", host)
+		}
+		return nil
+	}
+
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+
+	if h, p, err := net.SplitHostPort(host); err == nil && isPort(p) {
+		return fmt.Errorf("%q contains a port, use \"ip\": %q and \"port\": %s instead", host, h, p)
+	}
+
+	if len(host) > 253 {
+		return fmt.Errorf("hostname %q is longer than 253 characters", host)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !validHostLabel(label) {
+			return fmt.Errorf("%q is neither an IP address nor a valid hostname", host)
+		}
+	}
+	return nil
+}
+
+// isPort reports whether s is a decimal port number.
+func isPort(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 16)
+	return err == nil
+}
+
+// validHostLabel checks one dot-separated label of a hostname (RFC 1123).
+func validHostLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePort checks that a configured port is in the valid TCP range.
+// 0 means unset and selects DefaultSSHPort.
+func validatePort(port int) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("port %d is out of range 1-65535 (0 or no port selects the default %d)", port, DefaultSSHPort)
+	}
+	return nil
+}
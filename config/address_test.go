@@ -0,0 +1,89 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr string // empty if valid
+	}{
+		{"10.0.0.1", ""},
+		{"fd00::10", ""},
+		{"[fd00::10]", ""},
+		{"node-1.example.com", ""},
+		{"node-1.example.com.", ""},
+		{"localhost", ""},
+		{"", "must not be empty"},
+		{"[10.0.0.1]", "not a valid bracketed IPv6 address"},
+		{"[fd00::10", "not a valid bracketed IPv6 address"},
+		{"fd00::10]", "not a valid bracketed IPv6 address"},
+		{"[[fd00::10]]", "not a valid bracketed IPv6 address"},
+		{"10.0.0.1:22", `use "ip": "10.0.0.1" and "port": 22 instead`},
+		{"[fd00::10]:2222", "not a valid bracketed IPv6 address"},
+		{"-node.example.com", "neither an IP address nor a valid hostname"},
+		{"node-.example.com", "neither an IP address nor a valid hostname"},
+		{"node..example.com", "neither an IP address nor a valid hostname"},
+		{"node_1.example.com", "neither an IP address nor a valid hostname"},
+		{"https://node.example.com", "neither an IP address nor a valid hostname"},
+		{strings.Repeat("a", 64) + ".com", "neither an IP address nor a valid hostname"},
+		{strings.Repeat("a.", 127) + "com", "longer than 253 characters"},
+	}
+	for _, tt := range tests {
+		err := validateAddress(tt.host)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("validateAddress(%q) = %v, want no error", tt.host, err)
+		case tt.wantErr != "" && err == nil:
+			t.Errorf("validateAddress(%q) = nil, want error containing %q", tt.host, tt.wantErr)
+		case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+			t.Errorf("validateAddress(%q) = %q, want error containing %q", tt.host, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePort(t *testing.T) {
+	tests := []struct {
+		port    int
+		wantErr string // empty if valid
+	}{
+		{0, ""}, // default port
+		{1, ""},
+		{22, ""},
+		{65535, ""},
+		{-1, "port -1 is out of range 1-65535 (0 or no port selects the default 22)"},
+		{65536, "port 65536 is out of range 1-65535 (0 or no port selects the default 22)"},
+	}
+	for _, tt := range tests {
+		err := validatePort(tt.port)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("validatePort(%d) = %v, want no error", tt.port, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("validatePort(%d) = %v, want %q", tt.port, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNodeAddress(t *testing.T) {
+	tests := []struct {
+		node    NodeConfig
+		address string
+		urlHost string
+	}{
+		{NodeConfig{IP: "10.0.0.1"}, "10.0.0.1:22", "10.0.0.1"},
+		{NodeConfig{IP: "node.example.com", Port: 2222}, "node.example.com:2222", "node.example.com"},
+		{NodeConfig{IP: "fd00::10"}, "[fd00::10]:22", "[fd00::10]"},
+		{NodeConfig{IP: "[fd00::10]", Port: 2222}, "[fd00::10]:2222", "[fd00::10]"},
+	}
+	for _, tt := range tests {
+		if got := tt.node.Address(); got != tt.address {
+			t.Errorf("Address() of %q = %q, want %q", tt.node.IP, got, tt.address)
+		}
+		if got := tt.node.URLHost(); got != tt.urlHost {
+			t.Errorf("URLHost() of %q = %q, want %q", tt.node.IP, got, tt.urlHost)
+		}
+	}
+}
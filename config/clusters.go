@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ClustersKey holds the named cluster profiles of a config file. Every
+// other top-level key is a shared setting of all profiles.
+const ClustersKey = "clusters"
+
+// ClusterNames returns the sorted names of the cluster profiles in a
+// config map, nil if it has none.
+func ClusterNames(data map[string]any) []string {
+	clusters, _ := data[ClustersKey].(map[string]any)
+	if len(clusters) == 0 {
+		return nil
+	}
+	return sortedKeys(clusters)
+}
+
+// SelectCluster returns the settings of one cluster profile: the shared
+// settings with the profile merged on top, see mergeSections. Lists like
+// masters and workers of a profile replace the shared ones as a whole. A
+// file without profiles is returned as is if name is empty.
+//
+// The artifacts k3s_token_file and kubeconfig are kept apart per profile:
+// unless the profile sets them itself, the name of the profile is added to
+// the shared path, e.g. master-node-token-prod and ~/.kube/config-prod.
+func SelectCluster(data map[string]any, name string) (map[string]any, error) {
+	names := ClusterNames(data)
+	if len(names) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("cluster %q selected, but the config has no %s", name, ClustersKey)
+		}
+		return data, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the config has cluster profiles, select one of %s", strings.Join(names, ", "))
+	}
+	profile, ok := data[ClustersKey].(map[string]any)[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	if !resourceNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%s.%s: cluster names may only contain lowercase letters, digits, '-' and '.'", ClustersKey, name)
+	}
+	overrides, ok := profile.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s: must be an object", ClustersKey, name)
+	}
+	if _, ok := overrides[ClustersKey]; ok {
+		return nil, fmt.Errorf("%s.%s.%s: cluster profiles cannot be nested", ClustersKey, name, ClustersKey)
+	}
+
+	shared := make(map[string]any, len(data))
+	for k, v := range data {
+		if k != ClustersKey {
+			shared[k] = v
+		}
+	}
+	for _, key := range []string{"k3s_token_file", "kubeconfig"} {
+		if p, ok := shared[key].(string); ok && p != "" {
+			shared[key] = clusterPath(p, name)
+		}
+	}
+	return mergeSections(shared, overrides), nil
+}
+
+// clusterPath adds the name of a cluster to a file name, before its
+// extension: token.txt becomes token-prod.txt.
+func clusterPath(path, cluster string) string {
+	ext := filepath.Ext(path)
+	if ext == filepath.Base(path) {
+		ext = "" // dot files like .token
+	}
+	return strings.TrimSuffix(path, ext) + "-" + cluster + ext
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectCluster(t *testing.T) {
+	data := m{
+		"k3s_token_file": "/etc/k3s/master-node-token",
+		"kubeconfig":     "~/.kube/config",
+		"domain":         "example.com",
+		"masters":        l{m{"ip": "10.0.0.1", "ssh_user": "root"}},
+		"nfs":            m{"export": "/mnt/nfs", "capacity": "100Gi"},
+		"clusters": m{
+			"dev": m{
+				"domain": "dev.example.com",
+				"nfs":    m{"enabled": false},
+			},
+			"prod": m{
+				"masters":        l{m{"ip": "10.1.0.1", "ssh_user": "ubuntu"}},
+				"nfs":            m{"capacity": "500Gi"},
+				"k3s_token_file": "/etc/k3s/prod-token",
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		want m
+	}{
+		{"dev", m{
+			"k3s_token_file": "/etc/k3s/master-node-token-dev",
+			"kubeconfig":     "~/.kube/config-dev",
+			"domain":         "dev.example.com",
+			"masters":        l{m{"ip": "10.0.0.1", "ssh_user": "root"}},
+			"nfs":            m{"export": "/mnt/nfs", "capacity": "100Gi", "enabled": false},
+		}},
+		{"prod", m{
+			"k3s_token_file": "/etc/k3s/prod-token",
+			"kubeconfig":     "~/.kube/config-prod",
+			"domain":         "example.com",
+			"masters":        l{m{"ip": "10.1.0.1", "ssh_user": "ubuntu"}},
+			"nfs":            m{"export": "/mnt/nfs", "capacity": "500Gi"},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := SelectCluster(data, tt.name)
+		if err != nil {
+			t.Errorf("SelectCluster(%q): %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SelectCluster(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelectClusterWithoutProfiles(t *testing.T) {
+	data := m{"domain": "example.com", "kubeconfig": "~/.kube/config"}
+	got, err := SelectCluster(data, "")
+	if err != nil || !reflect.DeepEqual(got, data) {
+		t.Errorf("SelectCluster() = %v, %v, want the config as is", got, err)
+	}
+}
+
+func TestSelectClusterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    m
+		cluster string
+		wantErr string
+	}{
+		{"no profiles", m{"domain": "example.com"}, "prod", "the config has no clusters"},
+		{"no selection", m{"clusters": m{"dev": m{}, "prod": m{}}}, "", "select one of dev, prod"},
+		{"unknown", m{"clusters": m{"dev": m{}}}, "prod", `unknown cluster "prod", expected one of dev`},
+		{"invalid name", m{"clusters": m{"Prod": m{}}}, "Prod", "clusters.Prod: cluster names may only contain"},
+		{"not an object", m{"clusters": m{"prod": "example.com"}}, "prod", "clusters.prod: must be an object"},
+		{"nested", m{"clusters": m{"prod": m{"clusters": m{}}}}, "prod", "clusters.prod.clusters: cluster profiles cannot be nested"},
+	}
+	for _, tt := range tests {
+		_, err := SelectCluster(tt.data, tt.cluster)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClusterPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"master-node-token", "master-node-token-prod"},
+		{"/etc/k3s/token.txt", "/etc/k3s/token-prod.txt"},
+		{"~/.kube/config", "~/.kube/config-prod"},
+		{"/etc/k3s/.token", "/etc/k3s/.token-prod"},
+		{"/etc/k3s.d/token", "/etc/k3s.d/token-prod"},
+	}
+	for _, tt := range tests {
+		if got := clusterPath(tt.path, "prod"); got != tt.want {
+			t.Errorf("clusterPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+// Optional components of the cluster, named by their config section.
+const (
+	ComponentNFS         = "nfs"
+	ComponentCertManager = "cert_manager"
+	ComponentRegistry    = "docker_registry"
+)
+
+// Component is embedded in the config section of an optional component.
+type Component struct {
+	// Enabled turns the component off with false; it is enabled if unset.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// IsEnabled reports whether the component is enabled.
+func (c Component) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Enabled reports whether an optional component (Component* constants) is
+// enabled. Only enabled components are validated and installed.
+func (c *AppConfig) Enabled(component string) bool {
+	switch component {
+	case ComponentNFS:
+		return c.NFS.IsEnabled()
+	case ComponentCertManager:
+		return c.CertManager.IsEnabled()
+	case ComponentRegistry:
+		return c.DockerRegistry.IsEnabled()
+	}
+	return true
+}
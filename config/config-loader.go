@@ -1,23 +1,18 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-// LoadConfig reads the JSON config file, decodes it and validates all fields.
-func LoadConfig(filename string) (*AppConfig, error) {
-	file, err := os.Open(filename)
+// LoadConfig builds the config of a cluster with ReadConfig and validates
+// all fields.
+func LoadConfig(filenames []string, cluster string) (*AppConfig, error) {
+	cfg, err := ReadConfig(filenames, cluster)
 	if err != nil {
-		return nil, fmt.Errorf("could not open config file: %w", err)
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	var cfg AppConfig
-	if err := decoder.Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("could not decode JSON: %w", err)
+		return nil, err
 	}
 
 	// Run validation on the decoded config
@@ -25,83 +20,116 @@ func LoadConfig(filename string) (*AppConfig, error) {
 		return nil, err
 	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
-// Validate checks that no required field is left empty.
-func (c *AppConfig) Validate() error {
-	// Check master nodes
-	for i, m := range c.Masters {
-		if m.IP == "" {
-			return fmt.Errorf("masters[%d].ip must not be empty", i)
-		}
-		if m.SSHUser == "" {
-			return fmt.Errorf("masters[%d].ssh_user must not be empty", i)
-		}
-		if m.SSHPass == "" {
-			return fmt.Errorf("masters[%d].ssh_pass must not be empty", i)
-		}
+// ReadConfig builds the effective config of a cluster without validating
+// it: the built-in defaults, then the config files in order, then the
+// selected cluster profile. Encrypted values are decrypted and secret
+// references resolved. cluster must be empty for files without profiles.
+func ReadConfig(filenames []string, cluster string) (*AppConfig, error) {
+	data, err := MergeFiles(filenames)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check worker nodes
-	for i, w := range c.Workers {
-		if w.IP == "" {
-			return fmt.Errorf("workers[%d].ip must not be empty", i)
-		}
-		if w.SSHUser == "" {
-			return fmt.Errorf("workers[%d].ssh_user must not be empty", i)
-		}
-		if w.SSHPass == "" {
-			return fmt.Errorf("workers[%d].ssh_pass must not be empty", i)
-		}
-	}
+	// Defaults are relative to the first file, like a single config file
+	dir := filepath.Dir(filenames[0])
+	defaults := Defaults()
+	resolvePaths(defaults, dir)
 
-	// Check registry settings
-	if c.DockerRegistry.URL == "" {
-		return fmt.Errorf("docker_registry.url must not be empty")
-	}
-	if c.DockerRegistry.PVCStorageCapacity == "" {
-		return fmt.Errorf("docker_registry.pvc_storage_capacity must not be empty")
-	}
-	if c.DockerRegistry.Pass == "" {
-		return fmt.Errorf("docker_registry.pass must not be empty")
+	if data, err = SelectCluster(Merge(defaults, data), cluster); err != nil {
+		return nil, err
 	}
-	if c.DockerRegistry.User == "" {
-		return fmt.Errorf("docker_registry.user must not be empty")
+	if err := DecryptValues(data); err != nil {
+		return nil, err
 	}
 
-	// Check K3s token file
-	if c.K3sTokenFile == "" {
-		return fmt.Errorf("k3s_token_file must not be empty")
+	var cfg AppConfig
+	if err := Decode(data, &cfg); err != nil {
+		return nil, fmt.Errorf("could not decode config: %w", err)
 	}
+	cfg.Cluster = cluster
 
-	// Check NFS settings
-	if c.NFS.Server == "" {
-		return fmt.Errorf("nfs.nfs_server must not be empty")
-	}
-	if c.NFS.NFS_User == "" {
-		return fmt.Errorf("nfs.nfs_user must not be empty")
+	if err := cfg.resolveSecrets(dir); err != nil {
+		return nil, err
 	}
-	if c.NFS.Export == "" {
-		return fmt.Errorf("nfs.nfs_pass must not be empty")
+	return &cfg, nil
+}
+
+// MergeFiles reads config files (JSON, YAML or TOML, by extension) and
+// merges them in order, each one over the previous ones, see Merge.
+// Relative paths are resolved against the directory of the file that sets
+// them.
+func MergeFiles(filenames []string) (map[string]any, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no config file given")
 	}
-	if c.NFS.Export == "" {
-		return fmt.Errorf("nfs.export must not be empty")
+
+	merged := map[string]any{}
+	for _, filename := range filenames {
+		data, err := ReadFile(filename)
+		if err != nil {
+			if len(filenames) > 1 {
+				err = fmt.Errorf("%s: %w", filename, err)
+			}
+			return nil, err
+		}
+		resolvePaths(data, filepath.Dir(filename))
+		merged = Merge(merged, data)
 	}
-	if c.NFS.Capacity == "" {
-		return fmt.Errorf("nfs.capacity must not be empty")
+	return merged, nil
+}
+
+// pathKeys are the keys of file paths in every config format, wherever they
+// appear, e.g. in nodes, bastion hosts and cluster profiles.
+var pathKeys = map[string]bool{
+	"k3s_token_file":   true,
+	"kubeconfig":       true,
+	"known_hosts_file": true,
+	"ssh_key_path":     true,
+	"nfs_ssh_key_path": true,
+}
+
+// resolvePaths makes relative file paths and "file:" secret references in
+// a generic config map relative to the directory of its config file, so the
+// installer can be started anywhere.
+func resolvePaths(data map[string]any, dir string) {
+	resolve := func(p string) string {
+		if p != "" && !filepath.IsAbs(p) && !strings.HasPrefix(p, "~") {
+			return filepath.Join(dir, p)
+		}
+		return p
 	}
+	walkStrings(data, "", func(path, value string) (string, error) {
+		if pathKeys[lastKey(path)] {
+			return resolve(value), nil
+		}
+		if ref, ok := strings.CutPrefix(value, "file:"); ok && credentialKeys[lastKey(path)] {
+			return "file:" + resolve(ref), nil
+		}
+		return value, nil
+	})
+}
 
-	// Check global settings
-	if c.Email == "" {
-		return fmt.Errorf("email must not be empty")
+// KubeconfigPath returns the local path of the kubeconfig fetched from the
+// first master, DefaultKubeconfig if unset.
+func (c *AppConfig) KubeconfigPath() string {
+	if c.Kubeconfig == "" {
+		return expandHome(DefaultKubeconfig)
 	}
-	if c.Domain == "" {
-		return fmt.Errorf("domain must not be empty")
+	return expandHome(c.Kubeconfig)
+}
+
+// expandHome replaces a leading "~/" with the home directory of the current user.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
 	}
-	if c.ClusterIssuerName == "" {
-		return fmt.Errorf("cluster_issuer_name must not be empty")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
 	}
-
-	return nil
+	return filepath.Join(home, rest)
 }
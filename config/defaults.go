@@ -0,0 +1,28 @@
+package config
+
+// Built-in defaults, used for fields that none of the config files set.
+const (
+	DefaultTokenFile         = "master-node-token"
+	DefaultKubeconfig        = "~/.kube/config"
+	DefaultClusterIssuerName = "letsencrypt-prod"
+	DefaultNFSExport         = "/mnt/k3s-nfs-localstorage"
+	DefaultNFSCapacity       = "100Gi"
+	DefaultRegistryCapacity  = "10Gi"
+)
+
+// Defaults returns the built-in defaults as a generic config map, the
+// bottom layer below all config files.
+func Defaults() map[string]any {
+	return map[string]any{
+		"k3s_token_file":      DefaultTokenFile,
+		"kubeconfig":          DefaultKubeconfig,
+		"cluster_issuer_name": DefaultClusterIssuerName,
+		"nfs": map[string]any{
+			"export":   DefaultNFSExport,
+			"capacity": DefaultNFSCapacity,
+		},
+		"docker_registry": map[string]any{
+			"pvc_storagy_capacity": DefaultRegistryCapacity,
+		},
+	}
+}
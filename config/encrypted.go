@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"igneos.cloud/kubernetes/k3s-installer/vault"
+)
+
+// credentialKeys are the keys of credential fields in every config format,
+// see secretFields. "pass" only exists in docker_registry.
+var credentialKeys = map[string]bool{
+	"ssh_pass":               true,
+	"ssh_key_passphrase":     true,
+	"become_pass":            true,
+	"nfs_pass":               true,
+	"nfs_ssh_key_passphrase": true,
+	"nfs_become_pass":        true,
+	"pass":                   true,
+}
+
+// DecryptValues replaces every encrypted "enc:" value in a generic config
+// map by its plaintext. Errors name the path of the value.
+func DecryptValues(data map[string]any) error {
+	return walkStrings(data, "", func(path, value string) (string, error) {
+		if !vault.IsEncryptedValue(value) {
+			return value, nil
+		}
+		plain, err := vault.DecryptValue(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: cannot decrypt: %w", path, err)
+		}
+		return plain, nil
+	})
+}
+
+// EncryptSecrets encrypts the credential fields of a generic config map and
+// returns how many were encrypted. Empty values, encrypted values and secret
+// references are left as they are.
+func EncryptSecrets(data map[string]any) (int, error) {
+	count := 0
+	err := walkStrings(data, "", func(path, value string) (string, error) {
+		if !credentialKeys[lastKey(path)] || value == "" || isReference(value) {
+			return value, nil
+		}
+		enc, err := vault.EncryptValue(value)
+		if err != nil {
+			return "", fmt.Errorf("%s: cannot encrypt: %w", path, err)
+		}
+		count++
+		return enc, nil
+	})
+	return count, err
+}
+
+// MaskValues replaces the plaintext credentials of a generic config map by
+// mask. Encrypted values and secret references are shown as they are.
+func MaskValues(data map[string]any, mask string) {
+	walkStrings(data, "", func(path, value string) (string, error) {
+		if !credentialKeys[lastKey(path)] || value == "" || isReference(value) {
+			return value, nil
+		}
+		return mask, nil
+	})
+}
+
+// isReference reports whether a value is an encrypted value or a secret reference.
+func isReference(value string) bool {
+	if vault.IsEncryptedValue(value) {
+		return true
+	}
+	for scheme := range secretResolvers {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastKey returns the last key of a path like "masters[0].ssh_pass".
+func lastKey(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+// walkStrings calls fn for every string in a generic config map with its
+// path and replaces the string by the result. Map keys are visited in
+// order, so errors are reported deterministically.
+func walkStrings(v any, path string, fn func(path, value string) (string, error)) error {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if s, ok := v[k].(string); ok {
+				res, err := fn(p, s)
+				if err != nil {
+					return err
+				}
+				v[k] = res
+				continue
+			}
+			if err := walkStrings(v[k], p, fn); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, val := range v {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if s, ok := val.(string); ok {
+				res, err := fn(p, s)
+				if err != nil {
+					return err
+				}
+				v[i] = res
+				continue
+			}
+			if err := walkStrings(val, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported config file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FormatOf returns the config format of a file by its extension:
+// .json, .yaml/.yml or .toml.
+func FormatOf(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("unknown config format of %s, expected .json, .yaml, .yml or .toml", filename)
+}
+
+// ReadFile reads a config file in any supported format into a generic map
+// with the same keys as the JSON format. Files without a known extension
+// are read as JSON.
+func ReadFile(filename string) (map[string]any, error) {
+	format, err := FormatOf(filename)
+	if err != nil {
+		format = FormatJSON
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open config file: %w", err)
+	}
+	return Parse(content, format)
+}
+
+// Parse decodes config content of the given format into a generic map.
+func Parse(content []byte, format string) (map[string]any, error) {
+	var data map[string]any
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err != nil {
+			return nil, fmt.Errorf("could not decode JSON: %w", err)
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, fmt.Errorf("could not decode YAML: %w", err)
+		}
+	case FormatTOML:
+		if _, err := toml.Decode(string(content), &data); err != nil {
+			return nil, fmt.Errorf("could not decode TOML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+
+	normalized, err := normalize(data)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := normalized.(map[string]any)
+	return m, nil
+}
+
+// Encode writes a generic config map in the given format.
+func Encode(data map[string]any, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(data); err != nil {
+			return nil, err
+		}
+		encoder.Close()
+	case FormatTOML:
+		if err := toml.NewEncoder(&buf).Encode(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode fills cfg from a generic config map. The map takes a round trip
+// through JSON, so all formats share the json tags and decoding rules.
+func Decode(data map[string]any, cfg *AppConfig) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, cfg)
+}
+
+// FromConfig converts a config into a generic config map for Encode.
+// Empty strings, false flags other than "enabled" and empty sections are
+// left out.
+func FromConfig(cfg *AppConfig) (map[string]any, error) {
+	content, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := Parse(content, FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+	prune(data)
+	return data, nil
+}
+
+// prune removes empty values from a generic config map, see FromConfig.
+func prune(m map[string]any) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			if v == "" {
+				delete(m, k)
+			}
+		case bool:
+			if !v && k != "enabled" {
+				delete(m, k)
+			}
+		case map[string]any:
+			if prune(v); len(v) == 0 {
+				delete(m, k)
+			}
+		case []any:
+			for _, item := range v {
+				if im, ok := item.(map[string]any); ok {
+					prune(im)
+				}
+			}
+		}
+	}
+}
+
+// normalize converts decoded values into plain JSON types that every
+// encoder understands: JSON numbers become int64 or float64, maps with
+// non-string keys become map[string]any and null values are dropped.
+func normalize(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case int:
+		return int64(v), nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			if val == nil {
+				continue
+			}
+			n, err := normalize(val)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = n
+		}
+		return out, nil
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			if val == nil {
+				continue
+			}
+			n, err := normalize(val)
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(k)] = n
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			n, err := normalize(val)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			n, err := normalize(val)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import "fmt"
+
+// nodeKey and portKey identify a node in the node lists, e.g. masters and
+// proxy_jump: hosts behind one address with different SSH ports are
+// different nodes.
+const (
+	nodeKey = "ip"
+	portKey = "port"
+)
+
+// Merge returns base with override applied on top, without changing
+// either map, as for config overlays. Sections present in both are merged
+// key by key and node lists are merged by ip and port: a node of override
+// with the ip and port of a node in base is merged into it, other nodes are
+// appended; a node without port has DefaultSSHPort. An empty list clears
+// the list of base, all other values of override replace those of base.
+func Merge(base, override map[string]any) map[string]any {
+	return merge(base, override, true)
+}
+
+// mergeSections is Merge without merging node lists: sections are merged
+// key by key, lists and all other values of override replace those of base.
+// Cluster profiles are applied this way, so that a profile states its nodes
+// instead of adding them to the shared ones.
+func mergeSections(base, override map[string]any) map[string]any {
+	return merge(base, override, false)
+}
+
+// merge implements Merge and, with byIP false, mergeSections.
+func merge(base, override map[string]any, byIP bool) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		switch v := v.(type) {
+		case map[string]any:
+			if section, ok := merged[k].(map[string]any); ok {
+				merged[k] = merge(section, v, byIP)
+				continue
+			}
+		case []any:
+			if nodes, ok := merged[k].([]any); ok && byIP && len(v) > 0 && isNodeList(nodes) && isNodeList(v) {
+				merged[k] = mergeNodes(nodes, v)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// isNodeList reports whether every item of a list is a node with an ip.
+func isNodeList(list []any) bool {
+	for _, item := range list {
+		node, ok := item.(map[string]any)
+		if !ok {
+			return false
+		}
+		if _, ok := node[nodeKey].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeID returns the ip and port of a node in a generic config map.
+func nodeID(node map[string]any) string {
+	port := any(DefaultSSHPort)
+	// JSON, YAML and TOML decode numbers to different types
+	if p, ok := node[portKey]; ok && fmt.Sprint(p) != "0" {
+		port = p
+	}
+	return fmt.Sprintf("%s|%v", node[nodeKey], port)
+}
+
+// mergeNodes merges two node lists by ip and port, keeping the order of base.
+func mergeNodes(base, override []any) []any {
+	merged := append([]any(nil), base...)
+	index := make(map[string]int, len(merged))
+	for i, item := range merged {
+		index[nodeID(item.(map[string]any))] = i
+	}
+	for _, item := range override {
+		node := item.(map[string]any)
+		id := nodeID(node)
+		if i, ok := index[id]; ok {
+			merged[i] = Merge(merged[i].(map[string]any), node)
+			continue
+		}
+		index[id] = len(merged)
+		merged = append(merged, node)
+	}
+	return merged
+}
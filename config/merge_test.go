@@ -0,0 +1,105 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// m and l shorten the generic config maps and lists of the tests.
+type m = map[string]any
+type l = []any
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     m
+		override m
+		want     m
+	}{
+		{"values replace",
+			m{"domain": "a.example.com", "email": "ops@example.com"},
+			m{"domain": "b.example.com"},
+			m{"domain": "b.example.com", "email": "ops@example.com"}},
+		{"sections merge key by key",
+			m{"nfs": m{"export": "/mnt/a", "capacity": "100Gi"}},
+			m{"nfs": m{"capacity": "500Gi"}},
+			m{"nfs": m{"export": "/mnt/a", "capacity": "500Gi"}}},
+		{"section replaces a value",
+			m{"cert_manager": nil},
+			m{"cert_manager": m{"enabled": false}},
+			m{"cert_manager": m{"enabled": false}}},
+		{"nodes merge by ip and port",
+			m{"workers": l{m{"ip": "10.0.0.21", "ssh_user": "root"}, m{"ip": "10.0.0.22", "ssh_user": "root"}}},
+			m{"workers": l{m{"ip": "10.0.0.22", "ssh_agent": true}, m{"ip": "10.0.0.23", "ssh_user": "ubuntu"}}},
+			m{"workers": l{
+				m{"ip": "10.0.0.21", "ssh_user": "root"},
+				m{"ip": "10.0.0.22", "ssh_user": "root", "ssh_agent": true},
+				m{"ip": "10.0.0.23", "ssh_user": "ubuntu"},
+			}}},
+		{"same ip on other ports",
+			m{"workers": l{m{"ip": "203.0.113.1", "port": 2201}, m{"ip": "203.0.113.1", "port": 2202}}},
+			m{"workers": l{m{"ip": "203.0.113.1", "port": 2202, "ssh_agent": true}, m{"ip": "203.0.113.1", "ssh_user": "root"}}},
+			m{"workers": l{
+				m{"ip": "203.0.113.1", "port": 2201},
+				m{"ip": "203.0.113.1", "port": 2202, "ssh_agent": true},
+				m{"ip": "203.0.113.1", "ssh_user": "root"},
+			}}},
+		{"missing port is the default port",
+			m{"workers": l{m{"ip": "10.0.0.21", "port": 22}, m{"ip": "10.0.0.22", "port": 0}}},
+			m{"workers": l{m{"ip": "10.0.0.21", "ssh_agent": true}, m{"ip": "10.0.0.22", "port": float64(22), "ssh_agent": true}}},
+			m{"workers": l{
+				m{"ip": "10.0.0.21", "port": 22, "ssh_agent": true},
+				m{"ip": "10.0.0.22", "port": float64(22), "ssh_agent": true},
+			}}},
+		{"nested node lists merge by ip",
+			m{"ssh": m{"proxy_jump": l{m{"ip": "bastion", "ssh_user": "jump"}}}},
+			m{"ssh": m{"proxy_jump": l{m{"ip": "bastion", "ssh_agent": true}}}},
+			m{"ssh": m{"proxy_jump": l{m{"ip": "bastion", "ssh_user": "jump", "ssh_agent": true}}}}},
+		{"empty list clears",
+			m{"workers": l{m{"ip": "10.0.0.21"}}},
+			m{"workers": l{}},
+			m{"workers": l{}}},
+		{"other lists replace",
+			m{"tags": l{"a", "b"}},
+			m{"tags": l{"c"}},
+			m{"tags": l{"c"}}},
+		{"nodes without ip replace",
+			m{"workers": l{m{"ip": "10.0.0.21"}}},
+			m{"workers": l{m{"ssh_user": "root"}}},
+			m{"workers": l{m{"ssh_user": "root"}}}},
+	}
+	for _, tt := range tests {
+		if got := Merge(tt.base, tt.override); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Merge() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeKeepsInputs(t *testing.T) {
+	base := m{"nfs": m{"capacity": "100Gi"}, "workers": l{m{"ip": "10.0.0.21"}}}
+	override := m{"nfs": m{"capacity": "500Gi"}, "workers": l{m{"ip": "10.0.0.21", "port": 2222}}}
+	Merge(base, override)
+
+	want := m{"nfs": m{"capacity": "100Gi"}, "workers": l{m{"ip": "10.0.0.21"}}}
+	if !reflect.DeepEqual(base, want) {
+		t.Errorf("Merge changed base to %v", base)
+	}
+}
+
+func TestMergeSections(t *testing.T) {
+	base := m{
+		"nfs":     m{"export": "/mnt/a", "capacity": "100Gi"},
+		"masters": l{m{"ip": "10.0.0.1", "ssh_user": "root"}},
+	}
+	override := m{
+		"nfs":     m{"capacity": "500Gi"},
+		"masters": l{m{"ip": "10.1.0.1", "ssh_user": "ubuntu"}},
+	}
+	want := m{
+		"nfs":     m{"export": "/mnt/a", "capacity": "500Gi"},
+		"masters": l{m{"ip": "10.1.0.1", "ssh_user": "ubuntu"}},
+	}
+	if got := mergeSections(base, override); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSections() = %v, want %v", got, want)
+	}
+}
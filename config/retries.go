@@ -0,0 +1,24 @@
+package config
+
+// RetryPolicy overrides how an installer step is retried after transient
+// failures. Unset fields keep the step's built-in value.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts including the first one
+	Attempts int `json:"attempts,omitempty"`
+	// InitialDelay is the wait before the second attempt, e.g. "2s"
+	InitialDelay Duration `json:"initial_delay,omitempty"`
+	// MaxDelay caps the exponentially growing wait, e.g. "30s"
+	MaxDelay Duration `json:"max_delay,omitempty"`
+}
+
+// Retries maps installer step names to their retry policy.
+type Retries map[string]RetryPolicy
+
+// For returns the retry policy of a step: its own entry or the "default" entry.
+func (r Retries) For(step string) (RetryPolicy, bool) {
+	if p, ok := r[step]; ok {
+		return p, true
+	}
+	p, ok := r[DefaultTimeoutKey]
+	return p, ok
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaURL is the JSON Schema dialect of Schema.
+const SchemaURL = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaHints adds enums, formats and descriptions to properties, keyed by
+// "<struct type>.<json key>". Everything else is derived from the structs.
+var schemaHints = map[string]map[string]any{
+	"Component.enabled":                   {"default": true},
+	"NodeConfig.ip":                       {"description": "IPv4 address, IPv6 address or hostname"},
+	"NodeConfig.port":                     {"minimum": 1, "maximum": 65535, "default": DefaultSSHPort},
+	"NodeConfig.become":                   {"enum": []string{BecomeSudo, BecomeDoas, BecomeSu, BecomeNone}, "default": BecomeSudo},
+	"NodeConfig.proxy_jump":               {"description": "bastion hosts in order, the first one is dialed directly"},
+	"NFSConfig.network_CIDR":              {"description": "client network allowed to mount the export, e.g. 10.0.0.0/24"},
+	"NFSConfig.nfs_port":                  {"minimum": 1, "maximum": 65535, "default": DefaultSSHPort},
+	"NFSConfig.nfs_become":                {"enum": []string{BecomeSudo, BecomeDoas, BecomeSu, BecomeNone}, "default": BecomeSudo},
+	"NFSConfig.server":                    {"description": "address the cluster mounts the export from, nfs_server if empty"},
+	"NFSConfig.export":                    {"description": "absolute path of the export on the NFS server", "default": DefaultNFSExport},
+	"NFSConfig.capacity":                  {"pattern": quantityPattern.String(), "default": DefaultNFSCapacity},
+	"DockerRegistry.url":                  {"format": "hostname", "description": "host name of the registry ingress"},
+	"DockerRegistry.pvc_storagy_capacity": {"pattern": quantityPattern.String(), "default": DefaultRegistryCapacity},
+	"SSHConfig.host_key_policy":           {"enum": []string{HostKeyPolicyStrict, HostKeyPolicyTOFU}, "default": HostKeyPolicyStrict},
+	"AppConfig.email":                     {"format": "email"},
+	"AppConfig.domain":                    {"format": "hostname"},
+	"AppConfig.k3s_token_file":            {"default": DefaultTokenFile},
+	"AppConfig.cluster_issuer_name":       {"pattern": resourceNamePattern.String(), "default": DefaultClusterIssuerName},
+	"AppConfig.kubeconfig":                {"default": DefaultKubeconfig, "description": "local path of the kubeconfig fetched from the first master"},
+	"AppConfig.timeouts":                  {"description": "maximum duration per installer step, \"default\" applies to all others"},
+	"AppConfig.retries":                   {"description": "retry policy per installer step, \"default\" applies to all others"},
+}
+
+// Schema returns a JSON Schema of the config file for editor completion
+// and validation. It is derived from the json tags of AppConfig.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	root := structSchema(reflect.TypeOf(AppConfig{}), defs)
+	root["$schema"] = SchemaURL
+	root["title"] = "k3s-installer config"
+	root["$defs"] = defs
+	props := root["properties"].(map[string]any)
+	// Allows "$schema": "config.schema.json" in the config itself
+	props["$schema"] = map[string]any{"type": "string"}
+	// Cluster profiles override any of the shared settings
+	props[ClustersKey] = map[string]any{
+		"type":                 "object",
+		"description":          "named cluster profiles, merged over the shared settings",
+		"propertyNames":        map[string]any{"pattern": resourceNamePattern.String()},
+		"additionalProperties": map[string]any{"$ref": "#"},
+	}
+	return root
+}
+
+// typeSchema returns the schema of a Go type. Named structs other than
+// the root are stored in defs and referenced, which also handles the
+// recursive proxy_jump of NodeConfig.
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	if t == reflect.TypeOf(Duration(0)) {
+		return map[string]any{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`, "examples": []string{"90s", "10m"}}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // placeholder against recursion
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the object schema of a struct from its json tags.
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		// Fields of embedded structs like Component are inlined, as in encoding/json
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range structSchema(f.Type, defs)["properties"].(map[string]any) {
+				props[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := typeSchema(f.Type, defs)
+		for k, v := range schemaHints[t.Name()+"."+name] {
+			prop[k] = v
+		}
+		props[name] = prop
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
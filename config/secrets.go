@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// secretField is a credential in the configuration together with its path,
+// e.g. "masters[0].ssh_pass".
+type secretField struct {
+	path  string
+	value *string
+}
+
+// secretFields returns every credential field: SSH, become and key
+// passwords of all nodes and bastion hosts, the NFS password and the
+// registry password.
+func (c *AppConfig) secretFields() []secretField {
+	var fields []secretField
+
+	var addNodes func(prefix string, nodes []NodeConfig)
+	addNodes = func(prefix string, nodes []NodeConfig) {
+		for i := range nodes {
+			n := &nodes[i]
+			p := fmt.Sprintf("%s[%d]", prefix, i)
+			fields = append(fields,
+				secretField{p + ".ssh_pass", &n.SSHPass},
+				secretField{p + ".ssh_key_passphrase", &n.SSHKeyPassphrase},
+				secretField{p + ".become_pass", &n.BecomePass},
+			)
+			addNodes(p+".proxy_jump", n.ProxyJump)
+		}
+	}
+
+	addNodes("masters", c.Masters)
+	addNodes("workers", c.Workers)
+	addNodes("ssh.proxy_jump", c.SSH.ProxyJump)
+	fields = append(fields,
+		secretField{"nfs.nfs_pass", &c.NFS.NFS_Pass},
+		secretField{"nfs.nfs_ssh_key_passphrase", &c.NFS.NFS_KeyPassphrase},
+		secretField{"nfs.nfs_become_pass", &c.NFS.NFS_BecomePass},
+	)
+	addNodes("nfs.nfs_proxy_jump", c.NFS.NFS_ProxyJump)
+	fields = append(fields, secretField{"docker_registry.pass", &c.DockerRegistry.Pass})
+	return fields
+}
+
+// Secrets returns the values of all credential fields. Empty values are omitted.
+func (c *AppConfig) Secrets() []string {
+	var secrets []string
+	for _, f := range c.secretFields() {
+		if *f.value != "" {
+			secrets = append(secrets, *f.value)
+		}
+	}
+	return secrets
+}
+
+// MaskSecrets replaces the value of every credential field that is set by mask.
+func (c *AppConfig) MaskSecrets(mask string) {
+	for _, f := range c.secretFields() {
+		if *f.value != "" {
+			*f.value = mask
+		}
+	}
+}
+
+// secretCommandTimeout bounds a "cmd:" secret reference.
+const secretCommandTimeout = 30 * time.Second
+
+// secretResolvers resolve the secret reference schemes. The argument is
+// the reference without its scheme prefix, dir the directory of the config file.
+var secretResolvers = map[string]func(ref, dir string) (string, error){
+	"env:":  resolveEnv,
+	"file:": resolveFile,
+	"cmd:":  resolveCommand,
+}
+
+// resolveSecrets replaces secret references in credential fields by their
+// values: "env:VAR" reads an environment variable, "file:/path" the content
+// of a file and "cmd:command" the output of a command. Other values are
+// left as they are.
+func (c *AppConfig) resolveSecrets(dir string) error {
+	for _, f := range c.secretFields() {
+		for scheme, resolve := range secretResolvers {
+			ref, ok := strings.CutPrefix(*f.value, scheme)
+			if !ok {
+				continue
+			}
+			value, err := resolve(ref, dir)
+			if err != nil {
+				return fmt.Errorf("%s: cannot resolve %q: %w", f.path, *f.value, err)
+			}
+			*f.value = value
+			break
+		}
+	}
+	return nil
+}
+
+func resolveEnv(name, _ string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// resolveFile reads a secret file without its trailing newline. Relative
+// paths are relative to the config file.
+func resolveFile(path, dir string) (string, error) {
+	if path = expandHome(path); !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// resolveCommand runs a command in the shell of the local machine and
+// returns its output without the trailing newline, e.g. "pass show k3s/ssh".
+func resolveCommand(command, dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			lines := strings.Split(msg, "\n")
+			return "", fmt.Errorf("%w: %s", err, lines[len(lines)-1])
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -2,23 +2,119 @@ package config
 
 // NodeConfig represents one node (master or worker)
 type NodeConfig struct {
-	IP      string `json:"ip"`
+	// IP is an IPv4 address, an IPv6 address (optionally bracketed) or a hostname.
+	IP string `json:"ip"`
+	// Port is the SSH port, 22 if unset.
+	Port    int    `json:"port,omitempty"`
 	SSHUser string `json:"ssh_user"`
 	SSHPass string `json:"ssh_pass"`
+	// SSHKeyPath points to a private key used for public key authentication.
+	SSHKeyPath string `json:"ssh_key_path"`
+	// SSHKeyPassphrase decrypts SSHKeyPath if the key is protected.
+	SSHKeyPassphrase string `json:"ssh_key_passphrase"`
+	// SSHAgent enables authentication via the ssh-agent behind SSH_AUTH_SOCK.
+	SSHAgent bool `json:"ssh_agent"`
+	// Become selects the privilege escalation: "sudo" (default), "doas", "su" or "none" for root logins.
+	Become string `json:"become,omitempty"`
+	// BecomePass is the sudo/doas password if it differs from SSHPass, or the root password for su.
+	BecomePass string `json:"become_pass,omitempty"`
+	// ProxyJump lists the bastion hosts to tunnel through, in order. It
+	// overrides ssh.proxy_jump; an empty list connects directly.
+	ProxyJump []NodeConfig `json:"proxy_jump,omitempty"`
+}
+
+// HasCredentials reports whether at least one SSH authentication method is configured.
+func (n NodeConfig) HasCredentials() bool {
+	return n.SSHPass != "" || n.SSHKeyPath != "" || n.SSHAgent
+}
+
+// Privilege escalation methods for NodeConfig.Become
+const (
+	BecomeSudo = "sudo"
+	BecomeDoas = "doas"
+	BecomeSu   = "su"
+	// BecomeNone runs commands directly, for nodes that are logged in as root.
+	BecomeNone = "none"
+)
+
+// BecomeMethod returns the configured privilege escalation, BecomeSudo if unset.
+func (n NodeConfig) BecomeMethod() string {
+	if n.Become == "" {
+		return BecomeSudo
+	}
+	return n.Become
+}
+
+// BecomePassword returns the password for privilege escalation: BecomePass if set, otherwise SSHPass.
+func (n NodeConfig) BecomePassword() string {
+	if n.BecomePass != "" {
+		return n.BecomePass
+	}
+	return n.SSHPass
 }
 
 // NFSConfig represents NFS settings
 type NFSConfig struct {
-	NetworkCIDR string `json:"network_CIDR"`
-	NFS_Server  string `json:"nfs_server"`
-	NFS_User    string `json:"nfs_user"`
-	NFS_Pass    string `json:"nfs_pass"`
-	Server      string `json:"server"`
-	Export      string `json:"export"`
-	Capacity    string `json:"capacity"`
+	Component
+	NetworkCIDR       string       `json:"network_CIDR"`
+	NFS_Server        string       `json:"nfs_server"`
+	NFS_Port          int          `json:"nfs_port,omitempty"`
+	NFS_User          string       `json:"nfs_user"`
+	NFS_Pass          string       `json:"nfs_pass"`
+	NFS_KeyPath       string       `json:"nfs_ssh_key_path"`
+	NFS_KeyPassphrase string       `json:"nfs_ssh_key_passphrase"`
+	NFS_SSHAgent      bool         `json:"nfs_ssh_agent"`
+	NFS_Become        string       `json:"nfs_become,omitempty"`
+	NFS_BecomePass    string       `json:"nfs_become_pass,omitempty"`
+	NFS_ProxyJump     []NodeConfig `json:"nfs_proxy_jump,omitempty"`
+	// Server is the address the cluster mounts the export from, if it
+	// differs from the SSH address NFS_Server, e.g. on a storage network.
+	Server   string `json:"server"`
+	Export   string `json:"export"`
+	Capacity string `json:"capacity"`
+}
+
+// Node returns the SSH connection settings of the NFS server.
+func (n NFSConfig) Node() NodeConfig {
+	return NodeConfig{
+		IP:               n.NFS_Server,
+		Port:             n.NFS_Port,
+		SSHUser:          n.NFS_User,
+		SSHPass:          n.NFS_Pass,
+		SSHKeyPath:       n.NFS_KeyPath,
+		SSHKeyPassphrase: n.NFS_KeyPassphrase,
+		SSHAgent:         n.NFS_SSHAgent,
+		Become:           n.NFS_Become,
+		BecomePass:       n.NFS_BecomePass,
+		ProxyJump:        n.NFS_ProxyJump,
+	}
+}
+
+// MountServer returns the address the cluster mounts the export from:
+// Server if set, otherwise the address of the NFS server node.
+func (n NFSConfig) MountServer() string {
+	if n.Server != "" {
+		return n.Server
+	}
+	return n.Node().Host()
+}
+
+// SetNode sets the SSH connection settings of the NFS server, the reverse of Node.
+func (n *NFSConfig) SetNode(node NodeConfig) {
+	n.NFS_Server = node.IP
+	n.NFS_Port = node.Port
+	n.NFS_User = node.SSHUser
+	n.NFS_Pass = node.SSHPass
+	n.NFS_KeyPath = node.SSHKeyPath
+	n.NFS_KeyPassphrase = node.SSHKeyPassphrase
+	n.NFS_SSHAgent = node.SSHAgent
+	n.NFS_Become = node.Become
+	n.NFS_BecomePass = node.BecomePass
+	n.NFS_ProxyJump = node.ProxyJump
 }
 
 type DockerRegistry struct {
+	Component
 	URL                string `json:"url"`
 	PVCStorageCapacity string `json:"pvc_storagy_capacity"`
 	User               string `json:"user"`
@@ -26,14 +122,46 @@ type DockerRegistry struct {
 	Local              bool   `json:"local"`
 }
 
+// Host key policies for SSHConfig.HostKeyPolicy
+const (
+	// HostKeyPolicyStrict only accepts hosts already present in known_hosts.
+	HostKeyPolicyStrict = "strict"
+	// HostKeyPolicyTOFU records the key of unknown hosts on first connect.
+	HostKeyPolicyTOFU = "tofu"
+)
+
+// SSHConfig holds settings shared by all SSH connections
+type SSHConfig struct {
+	// KnownHostsFile defaults to ~/.ssh/known_hosts
+	KnownHostsFile string `json:"known_hosts_file"`
+	// HostKeyPolicy is "strict" (default) or "tofu"
+	HostKeyPolicy string `json:"host_key_policy"`
+	// ProxyJump lists the bastion hosts used for every node without its own proxy_jump
+	ProxyJump []NodeConfig `json:"proxy_jump,omitempty"`
+}
+
 // AppConfig represents the entire configuration
 type AppConfig struct {
 	Masters           []NodeConfig   `json:"masters"`
 	Workers           []NodeConfig   `json:"workers"`
+	SSH               SSHConfig      `json:"ssh"`
 	K3sTokenFile      string         `json:"k3s_token_file"`
 	NFS               NFSConfig      `json:"nfs"`
 	DockerRegistry    DockerRegistry `json:"docker_registry"`
 	Email             string         `json:"email"`
 	Domain            string         `json:"domain"`
 	ClusterIssuerName string         `json:"cluster_issuer_name"`
+	// CertManager enables cert-manager, which needs Email and ClusterIssuerName
+	CertManager Component `json:"cert_manager"`
+	// EncryptArtifacts writes the node token and the kubeconfig encrypted with the vault key
+	EncryptArtifacts bool `json:"encrypt_artifacts,omitempty"`
+	// Kubeconfig is the local path of the kubeconfig, DefaultKubeconfig if unset
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Timeouts overrides the maximum duration of installer steps, e.g. {"install-k3s-master": "20m"}
+	Timeouts Timeouts `json:"timeouts,omitempty"`
+	// Retries overrides the retry policy of installer steps, e.g. {"apply-yaml": {"attempts": 8}}
+	Retries Retries `json:"retries,omitempty"`
+
+	// Cluster is the name of the selected cluster profile, empty without profiles
+	Cluster string `json:"-"`
 }
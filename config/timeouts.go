@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultTimeoutKey is the key in "timeouts" and "retries" that applies to
+// every step without its own entry.
+const DefaultTimeoutKey = "default"
+
+// Duration is a time.Duration written as a Go duration string ("90s", "15m").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"90s\" or \"15m\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Timeouts maps installer step names to their maximum duration.
+type Timeouts map[string]Duration
+
+// For returns the timeout of a step: its own entry, the "default" entry or
+// the given fallback.
+func (t Timeouts) For(step string, fallback time.Duration) time.Duration {
+	if d, ok := t[step]; ok {
+		return time.Duration(d)
+	}
+	if d, ok := t[DefaultTimeoutKey]; ok {
+		return time.Duration(d)
+	}
+	return fallback
+}
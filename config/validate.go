@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Problem is one invalid field of a config, e.g. {"masters[0].ip", "must not be empty"}.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError lists every problem found by Validate.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "config has %d problems:", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  " + p.String())
+	}
+	return b.String()
+}
+
+// quantityPattern matches Kubernetes storage quantities like "10Gi" or "1.5T".
+var quantityPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(Ki|Mi|Gi|Ti|Pi|Ei|k|M|G|T|P|E)?$`)
+
+// resourceNamePattern matches Kubernetes resource names (RFC 1123 subdomain).
+var resourceNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// validator collects problems instead of stopping at the first one.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{path, fmt.Sprintf(format, args...)})
+}
+
+// check adds err as a problem of path, if any.
+func (v *validator) check(path string, err error) {
+	if err != nil {
+		v.add(path, "%s", err.Error())
+	}
+}
+
+func (v *validator) required(path, value string) bool {
+	if value == "" {
+		v.add(path, "must not be empty")
+		return false
+	}
+	return true
+}
+
+// Validate checks all fields and reports every problem at once as a
+// *ValidationError, with the JSON path of each field.
+func (c *AppConfig) Validate() error {
+	v := &validator{}
+
+	if len(c.Masters) == 0 {
+		v.add("masters", "at least one master is required")
+	}
+	for i, m := range c.Masters {
+		v.node(fmt.Sprintf("masters[%d]", i), m, true)
+	}
+	for i, w := range c.Workers {
+		v.node(fmt.Sprintf("workers[%d]", i), w, true)
+	}
+
+	// SSH settings
+	switch c.SSH.HostKeyPolicy {
+	case "", HostKeyPolicyStrict, HostKeyPolicyTOFU:
+	default:
+		v.add("ssh.host_key_policy", "must be %q or %q", HostKeyPolicyStrict, HostKeyPolicyTOFU)
+	}
+	v.proxyJump("ssh.proxy_jump", c.SSH.ProxyJump)
+
+	// Timeouts and retries
+	for _, step := range sortedKeys(c.Timeouts) {
+		if c.Timeouts[step] <= 0 {
+			v.add("timeouts."+step, "must be a positive duration")
+		}
+	}
+	for _, step := range sortedKeys(c.Retries) {
+		p := c.Retries[step]
+		if p.Attempts < 0 || p.InitialDelay < 0 || p.MaxDelay < 0 {
+			v.add("retries."+step, "must not contain negative values")
+		}
+		if p.MaxDelay > 0 && p.InitialDelay > p.MaxDelay {
+			v.add("retries."+step+".initial_delay", "must not exceed max_delay")
+		}
+	}
+
+	v.required("k3s_token_file", c.K3sTokenFile)
+
+	if v.required("domain", c.Domain) {
+		v.check("domain", ValidateDomain(c.Domain))
+	}
+
+	// Optional components are only checked if enabled
+	if c.NFS.IsEnabled() {
+		v.nfs(c.NFS)
+	}
+	if c.CertManager.IsEnabled() {
+		v.certManager(c)
+	}
+	if c.DockerRegistry.IsEnabled() {
+		v.registry(c)
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// nfs checks the NFS server and export.
+func (v *validator) nfs(n NFSConfig) {
+	node := n.Node()
+	v.check("nfs.nfs_server", validateAddress(node.IP))
+	v.check("nfs.nfs_port", validatePort(node.Port))
+	v.required("nfs.nfs_user", node.SSHUser)
+	if !node.HasCredentials() {
+		v.add("nfs", "needs nfs_pass, nfs_ssh_key_path or nfs_ssh_agent")
+	}
+	v.become("nfs.nfs_become", node)
+	v.proxyJump("nfs.nfs_proxy_jump", n.NFS_ProxyJump)
+	if v.required("nfs.network_CIDR", n.NetworkCIDR) {
+		v.check("nfs.network_CIDR", ValidateCIDR(n.NetworkCIDR))
+	}
+	if n.Server != "" {
+		v.check("nfs.server", validateAddress(n.Server))
+	}
+	if v.required("nfs.export", n.Export) {
+		v.check("nfs.export", ValidateExportPath(n.Export))
+	}
+	v.quantity("nfs.capacity", n.Capacity)
+}
+
+// certManager checks the settings of the Let's Encrypt cluster issuer.
+func (v *validator) certManager(c *AppConfig) {
+	if v.required("email", c.Email) {
+		v.check("email", ValidateEmail(c.Email))
+	}
+	if v.required("cluster_issuer_name", c.ClusterIssuerName) {
+		v.check("cluster_issuer_name", ValidateResourceName(c.ClusterIssuerName))
+	}
+}
+
+// registry checks the registry settings and the components it depends on:
+// its volume uses the nfs-client storage class, and its TLS certificate
+// is issued by cert-manager unless it is local.
+func (v *validator) registry(c *AppConfig) {
+	r := c.DockerRegistry
+	if v.required("docker_registry.url", r.URL) {
+		v.check("docker_registry.url", ValidateDomain(r.URL))
+	}
+	v.quantity("docker_registry.pvc_storagy_capacity", r.PVCStorageCapacity)
+	v.required("docker_registry.user", r.User)
+	v.required("docker_registry.pass", r.Pass)
+
+	if !c.NFS.IsEnabled() {
+		v.add("docker_registry", "needs nfs for its volume, enable nfs or disable docker_registry")
+	}
+	if !r.Local && !c.CertManager.IsEnabled() {
+		v.add("docker_registry", "needs cert_manager for TLS, enable cert_manager or set docker_registry.local")
+	}
+}
+
+// node checks the address, user, credentials and privilege escalation of a
+// node. Bastion hosts are checked with hops set to false, as they cannot
+// have bastions of their own.
+func (v *validator) node(path string, n NodeConfig, hops bool) {
+	v.check(path+".ip", validateAddress(n.IP))
+	v.check(path+".port", validatePort(n.Port))
+	v.required(path+".ssh_user", n.SSHUser)
+	if !n.HasCredentials() {
+		v.add(path, "needs ssh_pass, ssh_key_path or ssh_agent")
+	}
+	v.become(path+".become", n)
+	if hops {
+		v.proxyJump(path+".proxy_jump", n.ProxyJump)
+	} else if len(n.ProxyJump) > 0 {
+		v.add(path+".proxy_jump", "is not supported, list all hops in order instead")
+	}
+}
+
+// proxyJump checks every bastion host of a chain.
+func (v *validator) proxyJump(path string, hops []NodeConfig) {
+	for i, hop := range hops {
+		v.node(fmt.Sprintf("%s[%d]", path, i), hop, false)
+	}
+}
+
+// become checks the privilege escalation method of a node.
+func (v *validator) become(path string, n NodeConfig) {
+	switch n.BecomeMethod() {
+	case BecomeSudo, BecomeDoas, BecomeNone:
+	case BecomeSu:
+		if n.BecomePass == "" {
+			v.add(path, "%q needs the root password in become_pass", BecomeSu)
+		}
+	default:
+		v.add(path, "must be one of %q, %q, %q or %q", BecomeSudo, BecomeDoas, BecomeSu, BecomeNone)
+	}
+}
+
+// quantity checks a required Kubernetes storage quantity.
+func (v *validator) quantity(path, value string) {
+	if v.required(path, value) {
+		v.check(path, ValidateQuantity(value))
+	}
+}
+
+// Validators of single values, shared by Validate and the config wizard.
+
+// ValidateAddress checks that host is an IP address or a hostname.
+func ValidateAddress(host string) error {
+	return validateAddress(host)
+}
+
+// ValidatePort checks a TCP port, 0 selects the default.
+func ValidatePort(port int) error {
+	return validatePort(port)
+}
+
+// ValidateDomain checks that name is a DNS name and not an IP address.
+func ValidateDomain(name string) error {
+	if net.ParseIP(strings.Trim(name, "[]")) != nil {
+		return fmt.Errorf("%q is an IP address, a domain name is required", name)
+	}
+	if strings.Contains(name, "://") || strings.Contains(name, "/") {
+		return fmt.Errorf("%q must be a domain name without scheme or path", name)
+	}
+	return validateAddress(name)
+}
+
+// ValidateCIDR checks a network in CIDR notation, e.g. "10.0.0.0/24".
+func ValidateCIDR(cidr string) error {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return fmt.Errorf("%q is not a CIDR like 10.0.0.0/24", cidr)
+	}
+	return nil
+}
+
+// ValidateQuantity checks a Kubernetes storage quantity, e.g. "10Gi".
+func ValidateQuantity(q string) error {
+	if !quantityPattern.MatchString(q) {
+		return fmt.Errorf("%q is not a Kubernetes quantity like 10Gi", q)
+	}
+	return nil
+}
+
+// ValidateEmail checks a plain email address without display name.
+func ValidateEmail(email string) error {
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return fmt.Errorf("%q is not a valid email address", email)
+	}
+	return nil
+}
+
+// ValidateResourceName checks a Kubernetes resource name.
+func ValidateResourceName(name string) error {
+	if !resourceNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid Kubernetes name (lowercase letters, digits, '-' and '.')", name)
+	}
+	return nil
+}
+
+// ValidateExportPath checks that an NFS export is an absolute path.
+func ValidateExportPath(p string) error {
+	if !path.IsAbs(p) {
+		return fmt.Errorf("%q must be an absolute path", p)
+	}
+	return nil
+}
+
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
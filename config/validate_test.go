@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a config that passes Validate with all components enabled.
+func validConfig() *AppConfig {
+	return &AppConfig{
+		Masters:      []NodeConfig{{IP: "10.0.0.1", SSHUser: "admin", SSHPass: "secret"}},
+		Workers:      []NodeConfig{{IP: "10.0.0.2", SSHUser: "admin", SSHKeyPath: "~/.ssh/id_ed25519"}},
+		K3sTokenFile: DefaultTokenFile,
+		NFS: NFSConfig{
+			NetworkCIDR:  "10.0.0.0/24",
+			NFS_Server:   "10.0.0.3",
+			NFS_User:     "admin",
+			NFS_SSHAgent: true,
+			Server:       "10.0.0.3",
+			Export:       DefaultNFSExport,
+			Capacity:     DefaultNFSCapacity,
+		},
+		DockerRegistry: DockerRegistry{
+			URL:                "registry.example.com",
+			PVCStorageCapacity: DefaultRegistryCapacity,
+			User:               "registry",
+			Pass:               "registry-pass",
+		},
+		Email:             "admin@example.com",
+		Domain:            "example.com",
+		ClusterIssuerName: DefaultClusterIssuerName,
+	}
+}
+
+func disabled() Component {
+	off := false
+	return Component{Enabled: &off}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *AppConfig)
+		want   []string // paths of the expected problems, in order
+	}{
+		{"valid", func(c *AppConfig) {}, nil},
+		{"no masters", func(c *AppConfig) { c.Masters = nil }, []string{"masters"}},
+		{"node fields", func(c *AppConfig) {
+			c.Workers[0] = NodeConfig{IP: "10.0.0.2:22", Port: 70000}
+		}, []string{"workers[0].ip", "workers[0].port", "workers[0].ssh_user", "workers[0]"}},
+		{"unknown become", func(c *AppConfig) { c.Masters[0].Become = "runas" }, []string{"masters[0].become"}},
+		{"su without password", func(c *AppConfig) { c.Masters[0].Become = BecomeSu }, []string{"masters[0].become"}},
+		{"su with password", func(c *AppConfig) {
+			c.Masters[0].Become = BecomeSu
+			c.Masters[0].BecomePass = "root-pass"
+		}, nil},
+		{"proxy jump", func(c *AppConfig) {
+			c.Masters[0].ProxyJump = []NodeConfig{{IP: "bastion", SSHUser: "jump", SSHAgent: true}, {IP: "bastion 2"}}
+		}, []string{"masters[0].proxy_jump[1].ip", "masters[0].proxy_jump[1].ssh_user", "masters[0].proxy_jump[1]"}},
+		{"proxy jump on a hop", func(c *AppConfig) {
+			hop := NodeConfig{IP: "bastion", SSHUser: "jump", SSHAgent: true}
+			hop.ProxyJump = []NodeConfig{{IP: "outer", SSHUser: "jump", SSHAgent: true}}
+			c.SSH.ProxyJump = []NodeConfig{hop}
+		}, []string{"ssh.proxy_jump[0].proxy_jump"}},
+		{"host key policy", func(c *AppConfig) { c.SSH.HostKeyPolicy = "accept-all" }, []string{"ssh.host_key_policy"}},
+		{"timeouts and retries", func(c *AppConfig) {
+			c.Timeouts = Timeouts{"facts": 0, "default": Duration(time.Minute)}
+			c.Retries = Retries{
+				"apply-yaml": {Attempts: -1},
+				"default":    {InitialDelay: Duration(time.Minute), MaxDelay: Duration(time.Second)},
+			}
+		}, []string{"timeouts.facts", "retries.apply-yaml", "retries.default.initial_delay"}},
+		{"domain", func(c *AppConfig) {
+			c.K3sTokenFile = ""
+			c.Domain = "10.0.0.1"
+		}, []string{"k3s_token_file", "domain"}},
+		{"nfs", func(c *AppConfig) {
+			c.NFS.NFS_SSHAgent = false
+			c.NFS.NetworkCIDR = "10.0.0.0"
+			c.NFS.Export = "mnt/nfs"
+			c.NFS.Capacity = "lots"
+		}, []string{"nfs", "nfs.network_CIDR", "nfs.export", "nfs.capacity"}},
+		{"nfs server defaults to nfs_server", func(c *AppConfig) { c.NFS.Server = "" }, nil},
+		{"nfs server", func(c *AppConfig) { c.NFS.Server = "nfs server" }, []string{"nfs.server"}},
+		{"cert manager", func(c *AppConfig) {
+			c.Email = "Admin <admin@example.com>"
+			c.ClusterIssuerName = "Letsencrypt"
+		}, []string{"email", "cluster_issuer_name"}},
+		{"registry", func(c *AppConfig) {
+			c.DockerRegistry.URL = "https://registry.example.com"
+			c.DockerRegistry.Pass = ""
+		}, []string{"docker_registry.url", "docker_registry.pass"}},
+		{"disabled components are not checked", func(c *AppConfig) {
+			c.NFS = NFSConfig{Component: disabled()}
+			c.CertManager = disabled()
+			c.DockerRegistry = DockerRegistry{Component: disabled()}
+			c.Email = ""
+		}, nil},
+		{"registry needs nfs", func(c *AppConfig) { c.NFS = NFSConfig{Component: disabled()} }, []string{"docker_registry"}},
+		{"registry needs cert manager", func(c *AppConfig) { c.CertManager = disabled() }, []string{"docker_registry"}},
+		{"local registry without cert manager", func(c *AppConfig) {
+			c.CertManager = disabled()
+			c.DockerRegistry.Local = true
+		}, nil},
+	}
+	for _, tt := range tests {
+		c := validConfig()
+		tt.modify(c)
+		err := c.Validate()
+
+		var got []string
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			for _, p := range verr.Problems {
+				got = append(got, p.Path)
+			}
+		} else if err != nil {
+			t.Errorf("%s: got %T %v, want a *ValidationError", tt.name, err, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got problems %v, want %v (%v)", tt.name, got, tt.want, err)
+		}
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	one := &ValidationError{Problems: []Problem{{"domain", "must not be empty"}}}
+	if got := one.Error(); got != "domain: must not be empty" {
+		t.Errorf("one problem: got %q", got)
+	}
+
+	two := &ValidationError{Problems: []Problem{
+		{"masters", "at least one master is required"},
+		{"domain", "must not be empty"},
+	}}
+	want := "config has 2 problems:\n  masters: at least one master is required\n  domain: must not be empty"
+	if got := two.Error(); got != want {
+		t.Errorf("two problems: got %q, want %q", got, want)
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	c := validConfig()
+	c.Masters[0].Become = BecomeSu
+	c.NFS = NFSConfig{Component: disabled()}
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want problems")
+	}
+	for _, want := range []string{
+		`masters[0].become: "su" needs the root password in become_pass`,
+		"docker_registry: needs nfs for its volume",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestMountServer(t *testing.T) {
+	tests := []struct {
+		nfs  NFSConfig
+		want string
+	}{
+		{NFSConfig{NFS_Server: "10.0.0.3"}, "10.0.0.3"},
+		{NFSConfig{NFS_Server: "[fd00::3]"}, "fd00::3"},
+		{NFSConfig{NFS_Server: "nas.example.com", Server: "10.1.0.3"}, "10.1.0.3"},
+	}
+	for _, tt := range tests {
+		if got := tt.nfs.MountServer(); got != tt.want {
+			t.Errorf("MountServer() of %+v = %q, want %q", tt.nfs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package facts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/remote"
+)
+
+// OS families
+const (
+	FamilyDebian  = "debian"
+	FamilyRHEL    = "rhel"
+	FamilySUSE    = "suse"
+	FamilyAlpine  = "alpine"
+	FamilyUnknown = "unknown"
+)
+
+// Init systems
+const (
+	InitSystemd = "systemd"
+	InitOpenRC  = "openrc"
+)
+
+// Interface is a network interface with its addresses in CIDR notation.
+type Interface struct {
+	Name      string
+	Addresses []string
+}
+
+// Facts describes a node as seen over SSH before anything is installed.
+type Facts struct {
+	Host        string
+	OSID        string // ID from /etc/os-release, e.g. "ubuntu"
+	OSName      string // PRETTY_NAME from /etc/os-release
+	OSVersion   string // VERSION_ID from /etc/os-release
+	OSFamily    string // one of the Family* constants
+	Arch        string // uname -m, e.g. "x86_64"
+	Kernel      string // uname -r
+	MemoryMiB   uint64
+	DiskFreeMiB uint64 // free space on /
+	InitSystem  string // one of the Init* constants or the name of PID 1
+	SELinux     string // output of getenforce, empty if SELinux is not present
+	Interfaces  []Interface
+}
+
+// gatherScript prints one section per fact, each introduced by a "@@name" line.
+const gatherScript = `echo @@os-release; cat /etc/os-release 2>/dev/null
+echo @@arch; uname -m
+echo @@kernel; uname -r
+echo @@memory; grep MemTotal /proc/meminfo
+echo @@disk; df -Pk / | tail -n 1
+echo @@init
+if [ -d /run/systemd/system ]; then echo systemd
+elif [ -d /run/openrc ] || command -v openrc >/dev/null 2>&1; then echo openrc
+else cat /proc/1/comm; fi
+echo @@selinux; getenforce 2>/dev/null
+echo @@interfaces; ip -o addr show 2>/dev/null
+`
+
+// cache keeps the facts of every node for the whole run.
+var cache sync.Map
+
+// Gather collects the facts of a node. The result is cached per node address.
+func Gather(ctx context.Context, node config.NodeConfig) (*Facts, error) {
+	if f, ok := cache.Load(node.Address()); ok {
+		return f.(*Facts), nil
+	}
+
+	res, err := remote.Run(ctx, node, gatherScript)
+	if err != nil {
+		return nil, fmt.Errorf("could not gather facts of %s: %w", node.IP, err)
+	}
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("could not gather facts of %s: %w", node.IP, err)
+	}
+
+	f := parse(res.Stdout)
+	f.Host = node.IP
+	cache.Store(node.Address(), f)
+	return f, nil
+}
+
+// parse reads the sections printed by gatherScript.
+func parse(out string) *Facts {
+	f := &Facts{OSFamily: FamilyUnknown}
+	var osRelease = map[string]string{}
+	var section string
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "@@") {
+			section = line[2:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		switch section {
+		case "os-release":
+			if k, v, ok := strings.Cut(line, "="); ok {
+				osRelease[k] = strings.Trim(v, `"'`)
+			}
+		case "arch":
+			f.Arch = line
+		case "kernel":
+			f.Kernel = line
+		case "memory":
+			// MemTotal:        8029464 kB
+			if fields := strings.Fields(line); len(fields) >= 2 {
+				kb, _ := strconv.ParseUint(fields[1], 10, 64)
+				f.MemoryMiB = kb / 1024
+			}
+		case "disk":
+			// /dev/sda1  41152736 8035284 31003692  21% /
+			if fields := strings.Fields(line); len(fields) >= 4 {
+				kb, _ := strconv.ParseUint(fields[3], 10, 64)
+				f.DiskFreeMiB = kb / 1024
+			}
+		case "init":
+			f.InitSystem = line
+		case "selinux":
+			f.SELinux = line
+		case "interfaces":
+			// 2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0 ...
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[1] == "lo" {
+				continue
+			}
+			f.addAddress(strings.TrimSuffix(fields[1], ":"), fields[3])
+		}
+	}
+
+	f.OSID = osRelease["ID"]
+	f.OSName = osRelease["PRETTY_NAME"]
+	f.OSVersion = osRelease["VERSION_ID"]
+	f.OSFamily = family(osRelease["ID"], osRelease["ID_LIKE"])
+	return f
+}
+
+// addAddress appends an address to the named interface.
+func (f *Facts) addAddress(name, addr string) {
+	for i := range f.Interfaces {
+		if f.Interfaces[i].Name == name {
+			f.Interfaces[i].Addresses = append(f.Interfaces[i].Addresses, addr)
+			return
+		}
+	}
+	f.Interfaces = append(f.Interfaces, Interface{Name: name, Addresses: []string{addr}})
+}
+
+// family maps ID and ID_LIKE from /etc/os-release to an OS family.
+func family(id, idLike string) string {
+	for _, candidate := range append([]string{id}, strings.Fields(idLike)...) {
+		switch {
+		case candidate == "debian" || candidate == "ubuntu":
+			return FamilyDebian
+		case candidate == "rhel" || candidate == "fedora" || candidate == "centos" ||
+			candidate == "rocky" || candidate == "almalinux":
+			return FamilyRHEL
+		case candidate == "suse" || candidate == "sles" || strings.HasPrefix(candidate, "opensuse"):
+			return FamilySUSE
+		case candidate == "alpine":
+			return FamilyAlpine
+		}
+	}
+	return FamilyUnknown
+}
+
+// SELinuxEnabled reports whether SELinux is enforcing or permissive.
+func (f *Facts) SELinuxEnabled() bool {
+	return f.SELinux == "Enforcing" || f.SELinux == "Permissive"
+}
+
+// String returns a one-line summary, e.g.
+// "Ubuntu 22.04.4 LTS (debian), x86_64, kernel 5.15.0-105, 3921 MiB RAM, 30276 MiB free, systemd".
+func (f *Facts) String() string {
+	s := fmt.Sprintf("%s (%s), %s, kernel %s, %d MiB RAM, %d MiB free, %s",
+		f.OSName, f.OSFamily, f.Arch, f.Kernel, f.MemoryMiB, f.DiskFreeMiB, f.InitSystem)
+	if f.SELinux != "" {
+		s += ", SELinux " + strings.ToLower(f.SELinux)
+	}
+	return s
+}
@@ -0,0 +1,180 @@
+package facts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Minimum resources of a k3s node, see https://docs.k3s.io/installation/requirements
+const (
+	MinMemoryMiB   = 512
+	MinDiskFreeMiB = 2048
+)
+
+// supportedArchs are the architectures k3s publishes binaries for.
+var supportedArchs = map[string]bool{
+	"x86_64":  true,
+	"aarch64": true,
+	"arm64":   true,
+	"armv7l":  true,
+	"s390x":   true,
+}
+
+// Logical package names accepted by InstallCommand.
+const (
+	PackageCurl             = "curl"
+	PackageHtpasswd         = "htpasswd"
+	PackageNFSServer        = "nfs-server"
+	PackageContainerSELinux = "container-selinux"
+)
+
+// packageNames maps a logical package to the distribution packages per OS
+// family. A family without an entry does not need the package.
+var packageNames = map[string]map[string][]string{
+	PackageCurl: {
+		FamilyDebian: {"curl"},
+		FamilyRHEL:   {"curl"},
+		FamilySUSE:   {"curl"},
+		FamilyAlpine: {"curl"},
+	},
+	PackageHtpasswd: {
+		FamilyDebian: {"apache2-utils"},
+		FamilyRHEL:   {"httpd-tools"},
+		FamilySUSE:   {"apache2-utils"},
+		FamilyAlpine: {"apache2-utils"},
+	},
+	PackageNFSServer: {
+		FamilyDebian: {"nfs-kernel-server", "nfs-common"},
+		FamilyRHEL:   {"nfs-utils"},
+		FamilySUSE:   {"nfs-kernel-server"},
+		FamilyAlpine: {"nfs-utils"},
+	},
+	PackageContainerSELinux: {
+		FamilyRHEL: {"container-selinux"},
+		FamilySUSE: {"container-selinux"},
+	},
+}
+
+// nfsServices is the name of the NFS server service per OS family.
+var nfsServices = map[string]string{
+	FamilyDebian: "nfs-kernel-server",
+	FamilyRHEL:   "nfs-server",
+	FamilySUSE:   "nfs-server",
+	FamilyAlpine: "nfs",
+}
+
+// Supported returns an error listing every reason why the host cannot be
+// a k3s node, or nil if it can.
+func (f *Facts) Supported() error {
+	problems := f.platformProblems()
+
+	if !supportedArchs[f.Arch] {
+		problems = append(problems, fmt.Sprintf("architecture %q is not supported by k3s", f.Arch))
+	}
+	if f.MemoryMiB < MinMemoryMiB {
+		problems = append(problems, fmt.Sprintf("%d MiB RAM is below the minimum of %d MiB", f.MemoryMiB, MinMemoryMiB))
+	}
+	if f.DiskFreeMiB < MinDiskFreeMiB {
+		problems = append(problems, fmt.Sprintf("%d MiB free disk space on / is below the minimum of %d MiB", f.DiskFreeMiB, MinDiskFreeMiB))
+	}
+	return f.unsupported(problems)
+}
+
+// SupportedNFSServer returns an error if the installer cannot set up an NFS
+// export on the host. The NFS server is no k3s node, so only its package
+// manager and init system matter.
+func (f *Facts) SupportedNFSServer() error {
+	return f.unsupported(f.platformProblems())
+}
+
+// platformProblems lists why packages and services of the host cannot be
+// managed: an unknown OS family or init system.
+func (f *Facts) platformProblems() []string {
+	var problems []string
+	if f.OSFamily == FamilyUnknown {
+		problems = append(problems, fmt.Sprintf("OS %q is not supported, only Debian/Ubuntu, RHEL/Rocky/Fedora, openSUSE/SLES and Alpine", f.OSName))
+	}
+	if f.InitSystem != InitSystemd && f.InitSystem != InitOpenRC {
+		problems = append(problems, fmt.Sprintf("init system %q is not supported, systemd or OpenRC is required", f.InitSystem))
+	}
+	return problems
+}
+
+// unsupported joins the problems of the host into one error, nil if there are none.
+func (f *Facts) unsupported(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s is not supported: %s", f.Host, strings.Join(problems, "; "))
+}
+
+// InstallCommand returns the shell command that installs the given logical
+// packages (Package* constants) with the package manager of the host. If the
+// host needs none of them, the command is a no-op.
+func (f *Facts) InstallCommand(packages ...string) (string, error) {
+	var pkgs []string
+	for _, p := range packages {
+		names, ok := packageNames[p]
+		if !ok {
+			return "", fmt.Errorf("unknown package %q", p)
+		}
+		pkgs = append(pkgs, names[f.OSFamily]...)
+	}
+	if len(pkgs) == 0 {
+		return "true", nil
+	}
+	list := strings.Join(pkgs, " ")
+
+	switch f.OSFamily {
+	case FamilyDebian:
+		return "apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y " + list, nil
+	case FamilyRHEL:
+		return "if command -v dnf >/dev/null 2>&1; then dnf install -y " + list + "; else yum install -y " + list + "; fi", nil
+	case FamilySUSE:
+		return "zypper --non-interactive install " + list, nil
+	case FamilyAlpine:
+		return "apk add --no-cache " + list, nil
+	}
+	return "", fmt.Errorf("no package manager known for %s (%s)", f.Host, f.OSName)
+}
+
+// NFSService returns the name of the NFS server service.
+func (f *Facts) NFSService() string {
+	return nfsServices[f.OSFamily]
+}
+
+// NobodyGroup returns the group used for anonymous NFS access, which is
+// "nogroup" on Debian and "nobody" elsewhere.
+func (f *Facts) NobodyGroup() string {
+	if f.OSFamily == FamilyDebian {
+		return "nogroup"
+	}
+	return "nobody"
+}
+
+// EnableServiceCommand returns a command that enables the service at boot
+// and starts it now.
+func (f *Facts) EnableServiceCommand(service string) string {
+	if f.InitSystem == InitOpenRC {
+		return "rc-update add " + service + " default && rc-service " + service + " start"
+	}
+	return "systemctl enable --now " + service
+}
+
+// StopServiceCommand returns a command that stops the service if it is
+// running and succeeds if it does not exist.
+func (f *Facts) StopServiceCommand(service string) string {
+	if f.InitSystem == InitOpenRC {
+		return "rc-service " + service + " stop || true"
+	}
+	return "systemctl stop " + service + " || true"
+}
+
+// ServiceActiveCommand returns a command that prints "active" and exits 0 if
+// the service is running.
+func (f *Facts) ServiceActiveCommand(service string) string {
+	if f.InitSystem == InitOpenRC {
+		return "if rc-service " + service + " status >/dev/null 2>&1; then echo active; else echo inactive; exit 3; fi"
+	}
+	return "systemctl is-active " + service
+}
@@ -0,0 +1,63 @@
+package facts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSupported(t *testing.T) {
+	k3sNode := Facts{Host: "node", OSFamily: FamilyDebian, Arch: "x86_64", InitSystem: InitSystemd, MemoryMiB: 2048, DiskFreeMiB: 20480}
+	small := Facts{Host: "nas", OSFamily: FamilyAlpine, Arch: "ppc64le", InitSystem: InitOpenRC, MemoryMiB: 256, DiskFreeMiB: 512}
+	unknown := Facts{Host: "box", OSFamily: FamilyUnknown, OSName: "Plan 9", Arch: "x86_64", InitSystem: "rc", MemoryMiB: 2048, DiskFreeMiB: 20480}
+
+	tests := []struct {
+		name    string
+		check   func(*Facts) error
+		facts   Facts
+		wantErr []string // substrings of the error, none if supported
+	}{
+		{"k3s node", (*Facts).Supported, k3sNode, nil},
+		{"small k3s node", (*Facts).Supported, small, []string{`architecture "ppc64le"`, "256 MiB RAM", "512 MiB free disk"}},
+		{"unknown k3s node", (*Facts).Supported, unknown, []string{`OS "Plan 9"`, `init system "rc"`}},
+		{"small NFS server", (*Facts).SupportedNFSServer, small, nil},
+		{"unknown NFS server", (*Facts).SupportedNFSServer, unknown, []string{`OS "Plan 9"`, `init system "rc"`}},
+	}
+	for _, tt := range tests {
+		err := tt.check(&tt.facts)
+		if len(tt.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: got %v, want no error", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: got no error, want %q", tt.name, tt.wantErr)
+			continue
+		}
+		for _, want := range tt.wantErr {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not contain %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestServiceCommands(t *testing.T) {
+	systemd := &Facts{InitSystem: InitSystemd}
+	openrc := &Facts{InitSystem: InitOpenRC}
+
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{systemd.EnableServiceCommand("nfs-server"), "systemctl enable --now nfs-server"},
+		{openrc.EnableServiceCommand("nfs"), "rc-update add nfs default && rc-service nfs start"},
+		{systemd.StopServiceCommand("k3s"), "systemctl stop k3s || true"},
+		{openrc.StopServiceCommand("k3s"), "rc-service k3s stop || true"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
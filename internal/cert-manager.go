@@ -1,7 +1,8 @@
 package internal
 
 import (
-	"log"
+	"context"
+	"fmt"
 
 	"igneos.cloud/kubernetes/k3s-installer/config"
 	"igneos.cloud/kubernetes/k3s-installer/remote"
@@ -9,12 +10,7 @@ import (
 )
 
 // InstallCertManager installs cert-manager and applies the ClusterIssuer.
-func InstallCertManager() {
-	cfg, err := config.LoadConfig("config.json")
-	if err != nil {
-		log.Fatalf("[ERROR] Failed to load configuration: %v", err)
-	}
-
+func InstallCertManager(ctx context.Context, cfg *config.AppConfig) error {
 	master := cfg.Masters[0]
 
 	utils.PrintSectionHeader(
@@ -22,23 +18,25 @@ func InstallCertManager() {
 	)
 
 	if err := ApplyRemoteYAML(
-		master.IP,
-		master.SSHUser,
-		master.SSHPass,
+		ctx, cfg, master,
 		"internal/templates/cert-manager/cert-manager.yaml",
 		"cert-manager.yaml",
 		nil,
 	); err != nil {
-		log.Fatalf("[ERROR] Failed to apply cert-manager: %v", err)
+		return fmt.Errorf("failed to apply cert-manager: %w", err)
 	}
 
 	// Step 2: Wait for the cert-manager webhook deployment to become ready
 	utils.PrintSectionHeader(
 		"Waiting for cert-manager webhook to become ready...", "[INFO]", utils.ColorBlue, false,
 	)
-	waitCmd := "kubectl -n cert-manager rollout status deploy/cert-manager-webhook --timeout=90s"
-	if err := remote.RemoteExec(master.SSHUser, master.SSHPass, master.IP, waitCmd); err != nil {
-		log.Fatalf("[ERROR] cert-manager webhook not ready: %v", err)
+	waitCmd := fmt.Sprintf("kubectl -n cert-manager rollout status deploy/cert-manager-webhook --timeout=%s",
+		stepTimeout(cfg, "cert-manager-ready"))
+	err := step(ctx, cfg, "cert-manager-ready", func(ctx context.Context) error {
+		return remote.ExecPrivileged(ctx, master, waitCmd)
+	})
+	if err != nil {
+		return fmt.Errorf("cert-manager webhook not ready: %w", err)
 	}
 
 	// Step 3: Apply ClusterIssuer with templated values
@@ -51,17 +49,16 @@ func InstallCertManager() {
 	}
 
 	if err := ApplyRemoteYAML(
-		master.IP,
-		master.SSHUser,
-		master.SSHPass,
+		ctx, cfg, master,
 		"internal/templates/cert-manager/clusterIssuer.yaml",
 		"clusterIssuer.yaml",
 		vars,
 	); err != nil {
-		log.Fatalf("[ERROR] Failed to apply ClusterIssuer: %v", err)
+		return fmt.Errorf("failed to apply ClusterIssuer: %w", err)
 	}
 
 	utils.PrintSectionHeader(
 		"cert-manager and ClusterIssuer successfully installed.", "[SUCCESS]", utils.ColorGreen, false,
 	)
+	return nil
 }
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -10,11 +11,7 @@ import (
 )
 
 // createRegistrySecretWithHtpasswd creates an htpasswd file and Kubernetes Secret on the master node
-func createRegistrySecretWithHtpasswd() error {
-	cfg, err := config.LoadConfig("config.json")
-	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
-	}
+func createRegistrySecretWithHtpasswd(ctx context.Context, cfg *config.AppConfig) error {
 	master := cfg.Masters[0]
 
 	namespace := "ic-docker-registry"
@@ -22,22 +19,26 @@ func createRegistrySecretWithHtpasswd() error {
 	pass := cfg.DockerRegistry.Pass
 	htpasswdPath := "/home/kubernetes/.htpasswd"
 
-	script := fmt.Sprintf(`echo '%[1]s' | sudo -S bash -c '
-kubectl create namespace %[5]s --dry-run=client -o yaml | kubectl apply -f -
-mkdir -p $(dirname %[2]s) && chmod 700 $(dirname %[2]s)
-htpasswd -b -c %[2]s %[3]s %[4]s
+	script := fmt.Sprintf(`set -e
+trap 'rm -f /tmp/registry-credentials-secret.yaml' EXIT
+kubectl create namespace %[4]s --dry-run=client -o yaml | kubectl apply -f -
+mkdir -p $(dirname %[1]s) && chmod 700 $(dirname %[1]s)
+# printf is a shell builtin, the password never appears as a process argument
+printf '%%s\n' %[3]s | htpasswd -i -c %[1]s %[2]s
 
 kubectl create secret generic registry-credentials \
-  --from-file=htpasswd=%[2]s \
-  -n %[5]s \
+  --from-file=htpasswd=%[1]s \
+  -n %[4]s \
   --dry-run=client -o yaml > /tmp/registry-credentials-secret.yaml
 
-kubectl apply -f /tmp/registry-credentials-secret.yaml -n %[5]s
-rm /tmp/registry-credentials-secret.yaml
-'`, master.SSHPass, htpasswdPath, user, pass, namespace)
+kubectl apply -f /tmp/registry-credentials-secret.yaml -n %[4]s
+`, htpasswdPath, remote.Quote(user), remote.Quote(pass), namespace)
 
 	log.Printf("[INFO] Creating registry Secret on %s in namespace %s…", master.IP, namespace)
-	if err := remote.RemoteExec(master.SSHUser, master.SSHPass, master.IP, script); err != nil {
+	err := step(ctx, cfg, "create-registry-secret", func(ctx context.Context) error {
+		return remote.ExecScript(ctx, master, "create-registry-secret", script)
+	})
+	if err != nil {
 		return fmt.Errorf("error creating registry Secret on %s: %w", master.IP, err)
 	}
 
@@ -46,13 +47,9 @@ rm /tmp/registry-credentials-secret.yaml
 }
 
 // InstallDockerRegistry deploys the Docker registry based on config
-func InstallDockerRegistry() {
-	cfg, err := config.LoadConfig("config.json")
-	if err != nil {
-		log.Fatalf("[ERROR] Failed to load configuration: %v", err)
-	}
-	if err := createRegistrySecretWithHtpasswd(); err != nil {
-		log.Fatalf("[ERROR] Failed to create registry Secret: %v", err)
+func InstallDockerRegistry(ctx context.Context, cfg *config.AppConfig) error {
+	if err := createRegistrySecretWithHtpasswd(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to create registry Secret: %w", err)
 	}
 
 	master := cfg.Masters[0]
@@ -136,8 +133,8 @@ func InstallDockerRegistry() {
 	for _, step := range steps {
 		if step.active {
 			utils.PrintSectionHeader(fmt.Sprintf("Applying %s", step.name), "[INFO]", utils.ColorBlue, false)
-			if err := ApplyRemoteYAML(master.IP, master.SSHUser, master.SSHPass, step.template, step.remotePath, step.vars); err != nil {
-				log.Fatalf("[ERROR] Step '%s' failed: %v", step.name, err)
+			if err := ApplyRemoteYAML(ctx, cfg, master, step.template, step.remotePath, step.vars); err != nil {
+				return fmt.Errorf("step '%s' failed: %w", step.name, err)
 			}
 		}
 	}
@@ -152,5 +149,6 @@ func InstallDockerRegistry() {
 		fmt.Printf("→ docker login %s\n", cfg.DockerRegistry.URL)
 	}
 	fmt.Printf("→ Username: %s\n", cfg.DockerRegistry.User)
-	fmt.Printf("→ Password: %s\n", cfg.DockerRegistry.Pass)
+	fmt.Println("→ Password: docker_registry.pass from the configuration")
+	return nil
 }
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"fmt"
+
+	"igneos.cloud/kubernetes/k3s-installer/facts"
+)
+
+// k3sPrerequisites returns the script lines that prepare a host for the k3s
+// install script and the extra arguments for k3s itself. On hosts with
+// SELinux enabled, container-selinux is installed and k3s runs with
+// --selinux; the install script then adds the k3s-selinux policy from the
+// Rancher repository.
+func k3sPrerequisites(f *facts.Facts) (script string, args string, err error) {
+	installCurl, err := f.InstallCommand(facts.PackageCurl)
+	if err != nil {
+		return "", "", err
+	}
+	script = fmt.Sprintf("if ! command -v curl >/dev/null 2>&1; then\n\t%s\nfi\n", installCurl)
+
+	if f.SELinuxEnabled() {
+		installSELinux, err := f.InstallCommand(facts.PackageContainerSELinux)
+		if err != nil {
+			return "", "", err
+		}
+		script += installSELinux + "\n"
+		args = " --selinux"
+	}
+	return script, args, nil
+}
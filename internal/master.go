@@ -1,54 +1,46 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/user"
+	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
 	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/facts"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
 	"igneos.cloud/kubernetes/k3s-installer/remote"
+	"igneos.cloud/kubernetes/k3s-installer/retry"
 	"igneos.cloud/kubernetes/k3s-installer/utils"
+	"igneos.cloud/kubernetes/k3s-installer/vault"
 )
 
-func InstallK3sMaster() error {
-	cfg, err := config.LoadConfig("config.json")
+func InstallK3sMaster(ctx context.Context, cfg *config.AppConfig) error {
+	hostFacts, err := preflight(ctx, cfg, (*facts.Facts).Supported, cfg.Masters...)
 	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
+		return err
 	}
 
 	utils.PrintSectionHeader("Installing K3s on master nodes...", "[INFO]", utils.ColorBlue, true)
 
 	for _, master := range cfg.Masters {
 		user := master.SSHUser
-		pass := master.SSHPass
 		ip := master.IP
-		tlsDomain := cfg.Domain
 
 		log.Printf("[STEP] Installing K3s on %s (%s@%s)\n", ip, user, ip)
 
-		// Remote installation script with proper IP substitution
-		cmd := fmt.Sprintf(`echo '%s' | sudo -S bash -c '
-		if ! command -v htpasswd >/dev/null 2>&1; then
-			apt-get update && \
-			apt-get install -y apache2-utils
-		fi && \
-
-		curl -sfL https://get.k3s.io | INSTALL_K3S_EXEC="--write-kubeconfig-mode=644 --secrets-encryption --tls-san=%s" sh -s - server &&
-		mkdir -p /home/%s/.kube &&
-		cp /etc/rancher/k3s/k3s.yaml /home/%s/.kube/config &&
-		chown %s:%s /home/%s/.kube/config &&
-		chmod 600 /home/%s/.kube/config &&
-		SERVER_IP=$(hostname -I | awk "{print \$1}") &&
-		sed -i "s/127\\.0\\.0\\.1/$SERVER_IP/" /home/%s/.kube/config
-		'`, pass, tlsDomain, user, user, user, user, user, user, user)
-
-		if err := remote.RemoteExec(user, pass, ip, cmd); err != nil {
+		script, err := masterInstallScript(hostFacts[master.Address()], master, cfg.Domain)
+		if err != nil {
+			return err
+		}
+
+		err = step(ctx, cfg, "install-k3s-master", func(ctx context.Context) error {
+			return remote.ExecScript(ctx, master, "install-k3s-master", script)
+		})
+		if err != nil {
 			return fmt.Errorf("failed to install K3s on %s: %w", ip, err)
 		}
 
@@ -59,11 +51,11 @@ func InstallK3sMaster() error {
 	master := cfg.Masters[0]
 	utils.PrintSectionHeader("[INFO] Fetching node token and kubeconfig...", "[INFO]", utils.ColorBlue, false)
 
-	if err := fetchK3sToken(master.IP, master.SSHUser, master.SSHPass, cfg.K3sTokenFile); err != nil {
+	if err := fetchK3sToken(ctx, cfg, master); err != nil {
 		return fmt.Errorf("failed to fetch node-token: %w", err)
 	}
 
-	if err := fetchKubeconfigLocal(master.IP, master.SSHUser, master.SSHPass); err != nil {
+	if err := fetchKubeconfigLocal(ctx, cfg, master); err != nil {
 		return fmt.Errorf("failed to fetch kubeconfig: %w", err)
 	}
 
@@ -71,34 +63,69 @@ func InstallK3sMaster() error {
 	return nil
 }
 
-func fetchK3sToken(masterHost, user, password, tokenFile string) error {
-	msg := fmt.Sprintf("Read node-token of Master (%s)...", masterHost)
-	utils.PrintSectionHeader(msg, "[INFO]", utils.ColorBlue, true)
+// masterInstallScript builds the root script that installs the k3s server
+// and copies the kubeconfig into the home directory of the SSH user. The
+// kubeconfig points to the configured address of the master, like the
+// local copy written by fetchKubeconfigLocal.
+func masterInstallScript(f *facts.Facts, master config.NodeConfig, tlsDomain string) (string, error) {
+	installHtpasswd, err := f.InstallCommand(facts.PackageHtpasswd)
+	if err != nil {
+		return "", err
+	}
+	prerequisites, k3sArgs, err := k3sPrerequisites(f)
+	if err != nil {
+		return "", err
+	}
 
-	var output string
-	var err error
-	const maxRetries = 10
+	// Remote installation script with proper IP substitution, run as root
+	installExec := "--write-kubeconfig-mode=644 --secrets-encryption --tls-san=" + tlsDomain + k3sArgs
+	return fmt.Sprintf(`set -e
+%[5]sif ! command -v htpasswd >/dev/null 2>&1; then
+	%[3]s
+fi
+
+curl -sfL https://get.k3s.io | INSTALL_K3S_EXEC=%[1]s sh -s - server
+
+USER_HOME=$(awk -F: -v u=%[2]s '$1 == u { print $6 }' /etc/passwd)
+mkdir -p "$USER_HOME/.kube"
+cp /etc/rancher/k3s/k3s.yaml "$USER_HOME/.kube/config"
+chown %[2]s: "$USER_HOME/.kube/config"
+chmod 600 "$USER_HOME/.kube/config"
+SERVER=%[4]s
+sed -i "s|https://127\.0\.0\.1:|https://$SERVER:|" "$USER_HOME/.kube/config"
+`, remote.Quote(installExec), remote.Quote(master.SSHUser), installHtpasswd, remote.Quote(master.URLHost()), prerequisites), nil
+}
 
-	cmd := fmt.Sprintf("echo '%s' | sudo -S cat /var/lib/rancher/k3s/server/node-token", password)
-	for i := 0; i < maxRetries; i++ {
-		output, err = remote.RemoteExecOutput(user, password, masterHost, cmd)
-		if err == nil {
-			break
-		}
-		msg := fmt.Sprintf("[WARN] Token is not available (Attempt %d/%d): %v", i+1, maxRetries, err)
-		utils.PrintSectionHeader(msg, "[WARN]", utils.ColorYellow, false)
-		time.Sleep(5 * time.Second)
-	}
+// fetchK3sToken reads the node token of the master and writes it to the
+// token file. The token appears a moment after the k3s server started, so a
+// missing or empty token is retried according to the "fetch-k3s-token" policy.
+func fetchK3sToken(ctx context.Context, cfg *config.AppConfig, master config.NodeConfig) error {
+	msg := fmt.Sprintf("Read node-token of Master (%s)...", master.IP)
+	utils.PrintSectionHeader(msg, "[INFO]", utils.ColorBlue, true)
 
+	var token string
+	err := step(ctx, cfg, "fetch-k3s-token", func(ctx context.Context) error {
+		res, err := remote.RunPrivileged(ctx, master, "cat /var/lib/rancher/k3s/server/node-token")
+		if err != nil {
+			return err
+		}
+		if err := res.Err(); err != nil {
+			return retry.Transient(fmt.Errorf("node-token is not available: %w", err))
+		}
+		token = strings.TrimSpace(res.Stdout)
+		if token == "" {
+			return retry.Transient(fmt.Errorf("node-token on %s is empty", master.IP))
+		}
+		redact.Add(token)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("Fehler beim Abrufen des node-token: %v\nOutput: %s", err, output)
+		return fmt.Errorf("Fehler beim Abrufen des node-token: %w", err)
 	}
 
-	token := strings.TrimSpace(output)
-
-	// Token-Datei schreiben
-	result := strings.Split(token, ": ")
-	err = os.WriteFile(tokenFile, []byte(result[1]+"\n"), 0600)
+	// Token-Datei schreiben, verschlüsselt mit encrypt_artifacts
+	tokenFile := cfg.K3sTokenFile
+	err = vault.WriteFile(tokenFile, []byte(token+"\n"), cfg.EncryptArtifacts)
 	if err != nil {
 		return fmt.Errorf("Fehler beim Schreiben der Token-Datei (%s): %v", tokenFile, err)
 	}
@@ -107,34 +134,30 @@ func fetchK3sToken(masterHost, user, password, tokenFile string) error {
 	return nil
 }
 
-func fetchKubeconfigLocal(masterHost, userName, password string) error {
+func fetchKubeconfigLocal(ctx context.Context, cfg *config.AppConfig, master config.NodeConfig) error {
 
 	utils.PrintSectionHeader("Fetch kubeconfig of Master...", "[INFO]", utils.ColorBlue, true)
-	// SSH Verbindung
-	config := &ssh.ClientConfig{
-		User:            userName,
-		Auth:            []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", masterHost+":22", config)
-	if err != nil {
-		return fmt.Errorf("SSH-Fehler: %v", err)
-	}
-	defer client.Close()
+	return step(ctx, cfg, "fetch-kubeconfig", func(ctx context.Context) error {
+		return downloadKubeconfig(ctx, master, cfg.KubeconfigPath(), cfg.EncryptArtifacts)
+	})
+}
 
-	// SFTP-Client starten
-	sftpClient, err := sftp.NewClient(client)
+// downloadKubeconfig copies the kubeconfig of the master to dst and points
+// it at the master's address. With encrypt it is written encrypted to
+// dst.age instead.
+func downloadKubeconfig(ctx context.Context, master config.NodeConfig, dst string, encrypt bool) error {
+	// SFTP-Client auf der bestehenden SSH-Verbindung starten
+	sftpClient, err := remote.NewSFTP(ctx, master)
 	if err != nil {
 		return fmt.Errorf("SFTP-Fehler: %v", err)
 	}
 	defer sftpClient.Close()
 
-	// Zielpfad bestimmen (~/.kube/config)
-	usr, _ := user.Current()
-	kubeDir := usr.HomeDir + "/.kube"
-	os.MkdirAll(kubeDir, 0700)
-	dst := kubeDir + "/config"
+	// Zielverzeichnis anlegen (~/.kube)
+	os.MkdirAll(filepath.Dir(dst), 0700)
+	if encrypt {
+		dst += ".age"
+	}
 
 	// Quelldatei öffnen
 	srcFile, err := sftpClient.Open("/etc/rancher/k3s/k3s.yaml")
@@ -143,30 +166,19 @@ func fetchKubeconfigLocal(masterHost, userName, password string) error {
 	}
 	defer srcFile.Close()
 
-	// Lokale Datei schreiben
-	dstFile, err := os.Create(dst)
+	content, err := io.ReadAll(srcFile)
 	if err != nil {
-		return fmt.Errorf("Fehler beim Erstellen lokaler Datei: %v", err)
-	}
-	defer dstFile.Close()
-
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return fmt.Errorf("Fehler beim Kopieren: %v", err)
+		return remote.Cancelled(ctx, fmt.Errorf("Fehler beim Kopieren: %v", err))
 	}
 
 	// IP in Datei ersetzen
-	content, err := os.ReadFile(dst)
-	if err != nil {
-		return err
-	}
-
-	newContent := strings.ReplaceAll(string(content), "127.0.0.1", masterHost)
-	err = os.WriteFile(dst, []byte(newContent), 0600)
+	newContent := strings.ReplaceAll(string(content), "https://127.0.0.1:", "https://"+master.URLHost()+":")
+	err = vault.WriteFile(dst, []byte(newContent), encrypt)
 	if err != nil {
 		return fmt.Errorf("Fehler beim Schreiben der geänderten config: %v", err)
 	}
 
-	utils.PrintSectionHeader("kubeconfig is save successfully.", "[SUCCESS]", utils.ColorGreen, false)
+	msg := fmt.Sprintf("kubeconfig is save successfully to %s.", dst)
+	utils.PrintSectionHeader(msg, "[SUCCESS]", utils.ColorGreen, false)
 	return nil
 }
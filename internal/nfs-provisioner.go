@@ -1,18 +1,15 @@
 package internal
 
 import (
+	"context"
+	"fmt"
 	"log"
 
 	"igneos.cloud/kubernetes/k3s-installer/config"
 	"igneos.cloud/kubernetes/k3s-installer/utils"
 )
 
-func InstallNFSSubdirExternalProvisioner() {
-	cfg, err := config.LoadConfig("config.json")
-	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
-	}
-
+func InstallNFSSubdirExternalProvisioner(ctx context.Context, cfg *config.AppConfig) error {
 	master := cfg.Masters[0]
 
 	utils.PrintSectionHeader(
@@ -44,7 +41,7 @@ func InstallNFSSubdirExternalProvisioner() {
 			template:   "internal/templates/nfs/nfs-deployment.yaml",
 			remotePath: "nfs-deployment.yaml",
 			vars: map[string]string{
-				"{{NFS_SERVER}}": cfg.NFS.NFS_Server,
+				"{{NFS_SERVER}}": cfg.NFS.MountServer(),
 				"{{NFS_EXPORT}}": cfg.NFS.Export,
 			},
 		},
@@ -53,7 +50,7 @@ func InstallNFSSubdirExternalProvisioner() {
 			template:   "internal/templates/nfs/pv.yaml",
 			remotePath: "pv.yaml",
 			vars: map[string]string{
-				"{{NFS_SERVER}}":   cfg.NFS.NFS_Server,
+				"{{NFS_SERVER}}":   cfg.NFS.MountServer(),
 				"{{NFS_EXPORT}}":   cfg.NFS.Export,
 				"{{NFS_CAPACITY}}": cfg.NFS.Capacity,
 			},
@@ -64,8 +61,8 @@ func InstallNFSSubdirExternalProvisioner() {
 		utils.PrintSectionHeader(
 			"Applying "+step.name+"...", "[INFO]", utils.ColorBlue, false,
 		)
-		if err := ApplyRemoteYAML(master.IP, master.SSHUser, master.SSHPass, step.template, step.remotePath, step.vars); err != nil {
-			log.Fatalf("%s step failed: %v", step.name, err)
+		if err := ApplyRemoteYAML(ctx, cfg, master, step.template, step.remotePath, step.vars); err != nil {
+			return fmt.Errorf("%s step failed: %w", step.name, err)
 		}
 	}
 
@@ -73,4 +70,5 @@ func InstallNFSSubdirExternalProvisioner() {
 	utils.PrintSectionHeader(
 		"NFS Subdir External Provisioner successfully installed", "[SUCCESS]", utils.ColorGreen, false,
 	)
+	return nil
 }
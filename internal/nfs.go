@@ -1,24 +1,18 @@
 package internal
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"strings"
 
 	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/facts"
 	"igneos.cloud/kubernetes/k3s-installer/remote"
 	"igneos.cloud/kubernetes/k3s-installer/utils"
 )
 
-func MountNFS() {
-	cfg, err := config.LoadConfig("config.json")
-	if err != nil {
-		log.Fatalf(utils.ColorRed+"[ERROR] Failed to load configuration: %v"+utils.ColorReset, err)
-	}
-
-	nfsIP := cfg.NFS.NFS_Server
-	nfsUser := cfg.NFS.NFS_User
-	nfsPass := cfg.NFS.NFS_Pass
+func MountNFS(ctx context.Context, cfg *config.AppConfig) error {
+	nfsNode := cfg.NFS.Node()
+	nfsIP := nfsNode.IP
 	exportPath := cfg.NFS.Export
 	// New: define the client network CIDR (e.g. "192.168.179.0/24")
 	nfsCIDR := cfg.NFS.NetworkCIDR
@@ -26,50 +20,64 @@ func MountNFS() {
 	//fmt.Printf(utils.ColorBlue+"[INFO] Configuring NFS export on server %s (Export path: %s)\n"+ColotReset, nfsIP, exportPath)
 	utils.PrintSectionHeader(fmt.Sprintf("[INFO] Configuring NFS export on server %s (Export path: %s)\n", nfsIP, exportPath), "[INFO]", utils.ColorBlue, false)
 
+	hostFacts, err := preflight(ctx, cfg, (*facts.Facts).SupportedNFSServer, nfsNode)
+	if err != nil {
+		return err
+	}
+	nfsFacts := hostFacts[nfsNode.Address()]
+	installNFS, err := nfsFacts.InstallCommand(facts.PackageNFSServer)
+	if err != nil {
+		return err
+	}
+
 	// Shell script to set up NFS export
 	script := fmt.Sprintf(`
-    echo '%[5]s[INFO]%[6]s Installing nfs-kernel-server if not already present'
-    if ! dpkg -s nfs-kernel-server >/dev/null 2>&1; then apt-get update && apt-get install -y nfs-kernel-server nfs-common 
-    else echo '%[5]s[INFO]%[6]s nfs-kernel-server is already installed'; fi
+    EXPORT_PATH=%[1]s
+    CLIENT_CIDR=%[4]s
+
+    echo '%[5]s[INFO]%[6]s Installing the NFS server if not already present'
+    if ! command -v exportfs >/dev/null 2>&1; then %[7]s
+    else echo '%[5]s[INFO]%[6]s NFS server is already installed'; fi
 
-    echo '%[5]s[INFO]%[6]s Creating export directory: %[1]s'
-    mkdir -p '%[1]s' && chown nobody:nogroup '%[1]s' && chmod 0777 '%[1]s'
+    echo "%[5]s[INFO]%[6]s Creating export directory: $EXPORT_PATH"
+    mkdir -p "$EXPORT_PATH" && chown nobody:%[8]s "$EXPORT_PATH" && chmod 0777 "$EXPORT_PATH"
 
     echo '%[5]s[INFO]%[6]s Checking /etc/exports for existing entries'
-    if grep -qs '%[1]s' /etc/exports; then
+    if grep -qsF "$EXPORT_PATH" /etc/exports; then
       echo '%[5]s[INFO]%[6]s Export already exists in /etc/exports'
     else
       # Export with rw, sync, no_subtree_check, no_root_squash for the entire k3s node subnet
-      echo '%[1]s %[4]s(rw,sync,no_subtree_check,no_root_squash)' >> /etc/exports
+      echo "$EXPORT_PATH $CLIENT_CIDR(rw,sync,no_subtree_check,no_root_squash)" >> /etc/exports
       echo '%[3]s[SUCCESS]%[6]s Export added to /etc/exports'
     fi
 
+    echo '%[5]s[INFO]%[6]s Enabling the NFS server'
+    %[9]s
+
     echo '%[5]s[INFO]%[6]s Reloading NFS exports'
     exportfs -ra && exportfs -v
 
-    echo '%[3]s[SUCCESS]%[6]s NFS export is ready for clients in %[4]s'
+    echo "%[3]s[SUCCESS]%[6]s NFS export is ready for clients in $CLIENT_CIDR"
     `,
-		exportPath,       // %[1]s => export directory
-		nfsIP,            // %[2]s => server IP (unused in exports line)
-		utils.ColorGreen, // %[3]s => SUCCESS utils.Color
-		nfsCIDR,          // %[4]s => client network CIDR
-		utils.ColorBlue,  // %[5]s => INFO utils.Color
-		utils.ColorReset, // %[6]s => reset utils.Color
+		remote.Quote(exportPath), // %[1]s => export directory
+		nfsIP,                    // %[2]s => server IP (unused in exports line)
+		utils.ColorGreen,         // %[3]s => SUCCESS utils.Color
+		remote.Quote(nfsCIDR),    // %[4]s => client network CIDR
+		utils.ColorBlue,          // %[5]s => INFO utils.Color
+		utils.ColorReset,         // %[6]s => reset utils.Color
+		installNFS,               // %[7]s => package installation for the host's distribution
+		nfsFacts.NobodyGroup(),   // %[8]s => group for anonymous access
+		nfsFacts.EnableServiceCommand(nfsFacts.NFSService()), // %[9]s => enable and start the NFS server
 	)
 
-	// Prepare remote command
-	fullCommand := fmt.Sprintf("echo '%s' | sudo -S bash -c \"%s\"", nfsPass, escapeForDoubleQuotes(script))
-
-	// Execute remotely
-	err = remote.RemoteExec(nfsUser, nfsPass, nfsIP, fullCommand)
+	// Execute remotely as root
+	err = step(ctx, cfg, "configure-nfs-export", func(ctx context.Context) error {
+		return remote.ExecScript(ctx, nfsNode, "configure-nfs-export", script)
+	})
 	if err != nil {
-		log.Printf(utils.ColorRed + "[ERROR] Failed to configure NFS export on %s: %v" + utils.ColorReset)
-	} else {
-		utils.PrintSectionHeader(fmt.Sprintf("NFS export successfully configured on %s\n", nfsIP), "[OK]", utils.ColorGreen, true)
+		return fmt.Errorf("failed to configure NFS export on %s: %w", nfsIP, err)
 	}
-}
 
-// escapeForDoubleQuotes escapes all double quotes for bash -c execution
-func escapeForDoubleQuotes(input string) string {
-	return strings.ReplaceAll(input, `"`, `\"`)
+	utils.PrintSectionHeader(fmt.Sprintf("NFS export successfully configured on %s\n", nfsIP), "[OK]", utils.ColorGreen, true)
+	return nil
 }
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/facts"
+	"igneos.cloud/kubernetes/k3s-installer/utils"
+)
+
+// preflight gathers the facts of all nodes, prints a summary per host and
+// refuses hosts that fail check, e.g. (*facts.Facts).Supported for k3s
+// nodes, before anything is changed. A nil check accepts every host. The
+// facts are returned keyed by node address.
+func preflight(ctx context.Context, cfg *config.AppConfig, check func(*facts.Facts) error, nodes ...config.NodeConfig) (map[string]*facts.Facts, error) {
+	utils.PrintSectionHeader("Gathering host facts...", "[INFO]", utils.ColorBlue, true)
+
+	result := make(map[string]*facts.Facts, len(nodes))
+	var errs []error
+	for _, node := range nodes {
+		var f *facts.Facts
+		err := step(ctx, cfg, "facts", func(ctx context.Context) (err error) {
+			f, err = facts.Gather(ctx, node)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result[node.Address()] = f
+		utils.PrintSectionHeader(fmt.Sprintf("%s: %s", node.IP, f), "[FACTS]", utils.ColorBlue, false)
+
+		if check == nil {
+			continue
+		}
+		if err := check(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("preflight check failed:\n%w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/retry"
+	"igneos.cloud/kubernetes/k3s-installer/utils"
+)
+
+// defaultStepTimeout applies to steps without an entry in defaultTimeouts
+// and without a configured timeout.
+const defaultStepTimeout = 10 * time.Minute
+
+// defaultTimeouts are the built-in maximum durations of the installer steps.
+// They can be overridden per step in the "timeouts" section of the config.
+var defaultTimeouts = map[string]time.Duration{
+	"facts":                  2 * time.Minute,
+	"install-k3s-master":     15 * time.Minute,
+	"fetch-k3s-token":        2 * time.Minute,
+	"fetch-kubeconfig":       1 * time.Minute,
+	"install-k3s-agent":      15 * time.Minute,
+	"verify-k3s-agent":       1 * time.Minute,
+	"configure-nfs-export":   10 * time.Minute,
+	"apply-yaml":             2 * time.Minute,
+	"cert-manager-ready":     3 * time.Minute,
+	"create-registry-secret": 2 * time.Minute,
+	"uninstall-k3s":          10 * time.Minute,
+}
+
+// defaultRetries are the built-in retry policies of steps that are safe to
+// repeat. All other steps run once unless "retries" in the config says otherwise.
+var defaultRetries = map[string]retry.Policy{
+	"facts":                  withAttempts(3, 2*time.Second, 10*time.Second),
+	"fetch-k3s-token":        withAttempts(10, 2*time.Second, 10*time.Second),
+	"fetch-kubeconfig":       withAttempts(3, 2*time.Second, 10*time.Second),
+	"verify-k3s-agent":       withAttempts(10, 2*time.Second, 10*time.Second),
+	"apply-yaml":             withAttempts(5, 2*time.Second, 30*time.Second),
+	"cert-manager-ready":     withAttempts(3, 5*time.Second, 30*time.Second),
+	"create-registry-secret": withAttempts(5, 2*time.Second, 30*time.Second),
+}
+
+// withAttempts derives a policy from retry.Default.
+func withAttempts(attempts int, initial, maxDelay time.Duration) retry.Policy {
+	p := retry.Default
+	p.Attempts = attempts
+	p.InitialDelay = initial
+	p.MaxDelay = maxDelay
+	return p
+}
+
+// stepRetry returns the retry policy of the named step with the overrides
+// from the config applied.
+func stepRetry(cfg *config.AppConfig, name string) retry.Policy {
+	p, ok := defaultRetries[name]
+	if !ok {
+		p = retry.Default
+	}
+	if o, ok := cfg.Retries.For(name); ok {
+		if o.Attempts > 0 {
+			p.Attempts = o.Attempts
+		}
+		if o.InitialDelay > 0 {
+			p.InitialDelay = time.Duration(o.InitialDelay)
+		}
+		if o.MaxDelay > 0 {
+			p.MaxDelay = time.Duration(o.MaxDelay)
+		}
+	}
+	return p
+}
+
+// stepTimeout returns the timeout of the named step.
+func stepTimeout(cfg *config.AppConfig, name string) time.Duration {
+	fallback, ok := defaultTimeouts[name]
+	if !ok {
+		fallback = defaultStepTimeout
+	}
+	return cfg.Timeouts.For(name, fallback)
+}
+
+// step runs fn according to the step's retry policy. Every attempt gets a
+// context that ends after the step's timeout. If the step is cancelled, by
+// its timeout or by Ctrl-C, the returned error names the step and the reason.
+func step(ctx context.Context, cfg *config.AppConfig, name string, fn func(ctx context.Context) error) error {
+	policy := stepRetry(cfg, name)
+	return policy.Do(ctx, func(ctx context.Context) error {
+		return attempt(ctx, cfg, name, fn)
+	}, func(n int, err error, wait time.Duration) {
+		msg := fmt.Sprintf("%s failed (attempt %d/%d), retrying in %s: %v", name, n, policy.Attempts, wait.Round(100*time.Millisecond), err)
+		utils.PrintSectionHeader(msg, "[WARN]", utils.ColorYellow, false)
+	})
+}
+
+// attempt runs fn once within the step's timeout.
+func attempt(ctx context.Context, cfg *config.AppConfig, name string, fn func(ctx context.Context) error) error {
+	timeout := stepTimeout(cfg, name)
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("timed out after %s", timeout))
+	defer cancel()
+
+	err := fn(ctx)
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	// A step that ran out of time is not retried, even if the error of fn
+	// reads like a transient one ("i/o timeout")
+	if cause := context.Cause(ctx); !errors.Is(err, cause) {
+		return retry.Permanent(fmt.Errorf("step %s: %w: %v", name, cause, err))
+	}
+	return retry.Permanent(fmt.Errorf("step %s: %w", name, err))
+}
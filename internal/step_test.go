@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+func TestStepTimeoutIsNotRetried(t *testing.T) {
+	cfg := &config.AppConfig{
+		Timeouts: config.Timeouts{"apply-yaml": config.Duration(10 * time.Millisecond)},
+		Retries:  config.Retries{"apply-yaml": {Attempts: 3, InitialDelay: config.Duration(time.Millisecond)}},
+	}
+
+	calls := 0
+	err := step(context.Background(), cfg, "apply-yaml", func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return errors.New("dial tcp 10.0.0.1:6443: i/o timeout")
+	})
+	if calls != 1 {
+		t.Errorf("%d attempts, want 1", calls)
+	}
+	if err == nil || !strings.Contains(err.Error(), "step apply-yaml: timed out after 10ms") {
+		t.Errorf("got %v, want the timeout of the step", err)
+	}
+}
+
+func TestStepRetriesTransientErrors(t *testing.T) {
+	cfg := &config.AppConfig{
+		Retries: config.Retries{"apply-yaml": {Attempts: 3, InitialDelay: config.Duration(time.Millisecond)}},
+	}
+
+	calls := 0
+	err := step(context.Background(), cfg, "apply-yaml", func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("dial tcp 10.0.0.1:6443: connect: connection refused")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("got %v after %d attempts, want success after 3", err, calls)
+	}
+}
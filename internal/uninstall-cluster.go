@@ -2,33 +2,47 @@ package internal
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
+	"golang.org/x/term"
+
 	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/facts"
 	"igneos.cloud/kubernetes/k3s-installer/remote"
 	"igneos.cloud/kubernetes/k3s-installer/utils"
 )
 
+// AssumeYes answers all confirmation prompts with yes, e.g. for unattended runs.
+var AssumeYes bool
+
 // confirmAction prompts the user for confirmation before proceeding.
-// Returns true if the user confirms with 'y' or 'yes'.
-func confirmAction(prompt string) bool {
+// Returns true if the user confirms with 'y' or 'yes'. Without a terminal
+// nobody can answer, so an error is returned unless AssumeYes is set.
+func confirmAction(prompt string) (bool, error) {
+	if AssumeYes {
+		return true, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.New("confirmation required, use --yes")
+	}
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Printf("%s [y/N]: ", prompt)
 		input, err := reader.ReadString('\n')
 		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return false
+			return false, fmt.Errorf("error reading input: %w", err)
 		}
 
 		response := strings.ToLower(strings.TrimSpace(input))
 		switch response {
 		case "y", "yes":
-			return true
+			return true, nil
 		case "n", "no", "":
-			return false
+			return false, nil
 		default:
 			fmt.Println("Please respond with 'y' or 'n'.")
 		}
@@ -36,27 +50,48 @@ func confirmAction(prompt string) bool {
 }
 
 // UninstallK3sCluster uninstalls K3s from all nodes defined in the configuration.
-func UninstallK3sCluster() error {
-	if !confirmAction("Do you really want to uninstall the K3s cluster?") {
+func UninstallK3sCluster(ctx context.Context, cfg *config.AppConfig) error {
+	confirmed, err := confirmAction("Do you really want to uninstall the K3s cluster?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
 		fmt.Println("[ABORTED] Uninstallation canceled.")
 		return nil
 	}
 
-	cfg, err := config.LoadConfig("config.json")
+	// The stop commands depend on the init system of each node
+	nodes := append(append([]config.NodeConfig(nil), cfg.Masters...), cfg.Workers...)
+	hostFacts, err := preflight(ctx, cfg, nil, nodes...)
 	if err != nil {
-		return fmt.Errorf("error loading configuration: %w", err)
+		return err
 	}
 
-	// Determine the NFS export directory from config
-	exportPath := cfg.NFS.Export
-
-	for _, node := range append(cfg.Masters, cfg.Workers...) {
+	for _, node := range nodes {
 		utils.PrintSectionHeader(fmt.Sprintf("[INFO] Uninstalling K3s on %s...\n", node.IP), "[INFO]", utils.ColorBlue, false)
-		// Build a shell script to run on the remote host
-		script := fmt.Sprintf(`
+		script := uninstallScript(hostFacts[node.Address()], cfg)
+
+		// Execute the script on the remote host with root privileges
+		err := step(ctx, cfg, "uninstall-k3s", func(ctx context.Context) error {
+			return remote.ExecScript(ctx, node, "uninstall-k3s", script)
+		})
+		if err != nil {
+			return fmt.Errorf("error uninstalling K3s on %s: %w", node.IP, err)
+		}
+
+		utils.PrintSectionHeader(fmt.Sprintf("[OK] K3s successfully uninstalled from %s.\n", node.IP), "[OK]", utils.ColorGreen, true)
+	}
+
+	return nil
+}
+
+// uninstallScript builds the root script that stops and removes k3s on a
+// node and, if NFS is enabled, deletes the local storage directory.
+func uninstallScript(f *facts.Facts, cfg *config.AppConfig) string {
+	script := fmt.Sprintf(`
 # [INFO] Stop K3s services if active
-systemctl stop k3s || true
-systemctl stop k3s-agent || true
+%s
+%s
 
 # [INFO] Run uninstall scripts if present
 [ -f /usr/local/bin/k3s-uninstall.sh ] && /usr/local/bin/k3s-uninstall.sh
@@ -64,28 +99,23 @@ systemctl stop k3s-agent || true
 
 # [INFO] Remove remaining data directories
 rm -rf /etc/rancher /var/lib/rancher /var/lib/kubelet /etc/cni /opt/cni /var/lib/containerd
-
-# [INFO] Conditionally remove NFS local storage directory if it exists %s
-if [ -d "%s" ]; then
-    echo "[INFO] Removing %s"
-	sudo  rm -rf "%s"
+`, f.StopServiceCommand("k3s"), f.StopServiceCommand("k3s-agent"))
+
+	// Nothing to remove without NFS
+	if cfg.NFS.IsEnabled() && cfg.NFS.Export != "" {
+		script += fmt.Sprintf(`
+# [INFO] Conditionally remove NFS local storage directory if it exists
+EXPORT_PATH=%s
+if [ -d "$EXPORT_PATH" ]; then
+    echo "[INFO] Removing $EXPORT_PATH"
+    rm -rf "$EXPORT_PATH"
 else
-    echo "[INFO] %s not found, skipping"
+    echo "[INFO] $EXPORT_PATH not found, skipping"
 fi
-
-echo "[INFO] K3s services completely removed on $(hostname)"
-`, exportPath, exportPath, exportPath, exportPath, exportPath)
-
-		// Construct the command to execute the script with sudo privileges
-		fullCommand := fmt.Sprintf("echo '%s' | sudo -S bash -c \"%s\"", node.SSHPass, escapeForDoubleQuotes(script))
-
-		// Execute the command on the remote host
-		if err := remote.RemoteExec(node.SSHUser, node.SSHPass, node.IP, fullCommand); err != nil {
-			return fmt.Errorf("error uninstalling K3s on %s: %w", node.IP, err)
-		}
-
-		utils.PrintSectionHeader(fmt.Sprintf("[OK] K3s successfully uninstalled from %s.\n", node.IP), "[OK]", utils.ColorGreen, true)
+`, remote.Quote(cfg.NFS.Export))
 	}
 
-	return nil
+	return script + `
+echo "[INFO] K3s services completely removed on $(hostname)"
+`
 }
@@ -1,69 +1,86 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
 
 	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/facts"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
 	"igneos.cloud/kubernetes/k3s-installer/remote"
+	"igneos.cloud/kubernetes/k3s-installer/retry"
 	"igneos.cloud/kubernetes/k3s-installer/utils"
+	"igneos.cloud/kubernetes/k3s-installer/vault"
 )
 
 // InstallK3sWorker installiert den K3s-Agent auf einem Worker-Knoten via SSH
-func InstallK3sWorker() error {
+func InstallK3sWorker(ctx context.Context, cfg *config.AppConfig) error {
 	utils.PrintSectionHeader("Installing K3s worker nodes...", "[INFO]", utils.ColorBlue, true)
 
-	cfg, err := config.LoadConfig("config.json")
-	if err != nil {
-		return fmt.Errorf("Fehler beim Laden der Konfiguration: %v", err)
-	}
-
 	// Check if token file exists
 	if _, err := os.Stat(cfg.K3sTokenFile); os.IsNotExist(err) {
 		return fmt.Errorf("Token-Datei nicht gefunden: %s", cfg.K3sTokenFile)
 	}
 
-	// Read token file
-	tokenBytes, err := os.ReadFile(cfg.K3sTokenFile)
+	// Read token file, decrypting it if it was written with encrypt_artifacts
+	tokenBytes, err := vault.ReadFile(cfg.K3sTokenFile)
 	if err != nil {
 		return fmt.Errorf("Fehler beim Lesen der Token-Datei: %v", err)
 	}
 
 	token := strings.TrimSpace(string(tokenBytes))
+	redact.Add(token)
 
 	msg := fmt.Sprintf("K3s Token is loading successfully %s\n", cfg.K3sTokenFile)
 	utils.PrintSectionHeader(msg, "[INFO]", utils.ColorBlue, true)
 
+	hostFacts, err := preflight(ctx, cfg, (*facts.Facts).Supported, cfg.Workers...)
+	if err != nil {
+		return err
+	}
+
 	for _, worker := range cfg.Workers {
-		user := worker.SSHUser
-		password := worker.SSHPass
 		host := worker.IP
 
 		msg := fmt.Sprintf("[INFO] Installing k3s agent on worker node %s\n", host)
 		utils.PrintSectionHeader(msg, "[INFO]", utils.ColorBlue, false)
 
-		// Secure and robust installation command with set -e
-		installCmd := fmt.Sprintf(`
-		echo '%s' | sudo -S bash -c '
-		set -e
-		curl -sfL https://get.k3s.io | K3S_URL="https://%s:6443" K3S_TOKEN="%s" sh -s - agent
-		'`, password, cfg.Masters[0].IP, token)
+		prerequisites, k3sArgs, err := k3sPrerequisites(hostFacts[worker.Address()])
+		if err != nil {
+			return err
+		}
 
-		if err := remote.RemoteExec(user, password, host, installCmd); err != nil {
+		// Secure and robust installation command with set -e
+		installScript := fmt.Sprintf(`set -e
+%scurl -sfL https://get.k3s.io | K3S_URL=%s K3S_TOKEN=%s sh -s - agent%s
+`, prerequisites, remote.Quote("https://"+cfg.Masters[0].URLHost()+":6443"), remote.Quote(token), k3sArgs)
+
+		err = step(ctx, cfg, "install-k3s-agent", func(ctx context.Context) error {
+			return remote.ExecScript(ctx, worker, "install-k3s-agent", installScript)
+		})
+		if err != nil {
 			return fmt.Errorf("Fehler bei der Installation auf Worker %s: %v", host, err)
 		}
 
 		utils.PrintSectionHeader(fmt.Sprintf("Verify k3s-agent on %s...\n", host), "[INFO]", utils.ColorBlue, true)
 
-		checkCmd := "systemctl is-active --quiet k3s-agent"
-		err = remote.RemoteExec(user, password, host, checkCmd)
-		if err == nil {
-			utils.PrintSectionHeader(fmt.Sprintf("k3s agent om %s is active and ready!\n", host), "[SUCCESS]", utils.ColorGreen, false)
-
-		} else {
-			return fmt.Errorf("❌ k3s agent auf %s ist NICHT aktiv: %v", host, err)
+		// The agent may still be starting, so an inactive service is retried
+		err = step(ctx, cfg, "verify-k3s-agent", func(ctx context.Context) error {
+			res, err := remote.Run(ctx, worker, hostFacts[worker.Address()].ServiceActiveCommand("k3s-agent"))
+			if err != nil {
+				return fmt.Errorf("could not check k3s agent on %s: %w", host, err)
+			}
+			if state := strings.TrimSpace(res.Stdout); !res.Success() || state != "active" {
+				return retry.Transient(fmt.Errorf("❌ k3s agent auf %s ist NICHT aktiv: state %q, exit code %d", host, state, res.ExitCode))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+		utils.PrintSectionHeader(fmt.Sprintf("k3s agent om %s is active and ready!\n", host), "[SUCCESS]", utils.ColorGreen, false)
 	}
 
 	utils.PrintSectionHeader("K3s worker installation complete.", "[SUCCESS]", utils.ColorGreen, true)
@@ -1,17 +1,27 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
-	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/remote"
 	"igneos.cloud/kubernetes/k3s-installer/utils"
 )
 
-func ApplyRemoteYAML(host, user, password, localPath, remotePath string, replacements map[string]string) error {
+// ApplyRemoteYAML renders a template, uploads it to the node and applies it
+// with kubectl. Upload and apply are repeated together on transient failures,
+// e.g. while the API server or a webhook is not ready yet.
+func ApplyRemoteYAML(ctx context.Context, cfg *config.AppConfig, node config.NodeConfig, localPath, remotePath string, replacements map[string]string) error {
+	return step(ctx, cfg, "apply-yaml", func(ctx context.Context) error {
+		return applyRemoteYAML(ctx, node, localPath, remotePath, replacements)
+	})
+}
+
+func applyRemoteYAML(ctx context.Context, node config.NodeConfig, localPath, remotePath string, replacements map[string]string) error {
 	content, err := os.ReadFile(localPath)
 	if err != nil {
 		return fmt.Errorf("failed to read local YAML file: %w", err)
@@ -28,19 +38,7 @@ func ApplyRemoteYAML(host, user, password, localPath, remotePath string, replace
 	}
 	defer os.Remove(tmpFile)
 
-	sshConfig := &ssh.ClientConfig{
-		User:            user,
-		Auth:            []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	conn, err := ssh.Dial("tcp", host+":22", sshConfig)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer conn.Close()
-
-	sftpClient, err := sftp.NewClient(conn)
+	sftpClient, err := remote.NewSFTP(ctx, node)
 	if err != nil {
 		return fmt.Errorf("SFTP setup failed: %w", err)
 	}
@@ -58,20 +56,15 @@ func ApplyRemoteYAML(host, user, password, localPath, remotePath string, replace
 	defer srcFile.Close()
 
 	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy YAML to remote: %w", err)
+		dstFile.Close()
+		return remote.Cancelled(ctx, fmt.Errorf("failed to copy YAML to remote: %w", err))
 	}
-
-	session, err := conn.NewSession()
-	if err != nil {
-		return fmt.Errorf("SSH session creation failed: %w", err)
+	if err := dstFile.Close(); err != nil {
+		return remote.Cancelled(ctx, fmt.Errorf("failed to copy YAML to remote: %w", err))
 	}
-	defer session.Close()
-
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
 
-	applyCmd := fmt.Sprintf("echo '%s' | sudo -S bash -c 'kubectl apply -f %s && rm -f %s'", password, remotePath, remotePath)
-	if err := session.Run(applyCmd); err != nil {
+	applyScript := fmt.Sprintf("kubectl apply -f %[1]s && rm -f %[1]s", remote.Quote(remotePath))
+	if err := remote.ExecPrivileged(ctx, node, applyScript); err != nil {
 		return fmt.Errorf("failed to apply YAML remotely: %w", err)
 	}
 
@@ -0,0 +1,162 @@
+// Package redact masks known secrets (passwords, tokens) in console output,
+// transcripts and error messages.
+package redact
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every secret.
+const Mask = "[REDACTED]"
+
+// minLength is the length below which a value is not treated as a secret;
+// masking every occurrence of a one or two character string would make the
+// text unreadable.
+const minLength = 4
+
+// patterns match secrets that are not known in advance, like the node
+// token generated by the k3s server ("K10<ca hash>::server:<password>").
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`K10[0-9a-f]+::[A-Za-z0-9_-]+:[^\s'"]+`),
+}
+
+var (
+	mu       sync.RWMutex
+	secrets  = map[string]bool{}
+	replacer = strings.NewReplacer()
+)
+
+// Add registers secrets to be masked from now on. Empty and very short
+// values are ignored.
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	changed := false
+	for _, v := range values {
+		if len(v) < minLength {
+			continue
+		}
+		// A secret passed to a remote shell shows up in its quoted form
+		for _, form := range []string{v, strings.ReplaceAll(v, "'", `'\''`)} {
+			if !secrets[form] {
+				secrets[form] = true
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// Longest first, so a secret containing another one is masked as a whole
+	list := make([]string, 0, len(secrets))
+	for s := range secrets {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+
+	pairs := make([]string, 0, 2*len(list))
+	for _, s := range list {
+		pairs = append(pairs, s, Mask)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// String returns s with all registered secrets and k3s tokens masked.
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	s = replacer.Replace(s)
+	for _, p := range patterns {
+		s = p.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// Error returns err with all secrets masked in its message. The original
+// error stays reachable for errors.Is and errors.As.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := String(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// partialSecret returns the length of the longest end of data that is the
+// beginning of a secret, i.e. the bytes that must be held back because the
+// next write may complete the secret. The caller must hold mu.
+func partialSecret(data []byte) int {
+	longest := 0
+	for s := range secrets {
+		for k := min(len(s)-1, len(data)); k > longest; k-- {
+			if bytes.HasSuffix(data, []byte(s[:k])) {
+				longest = k
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// Writer masks secrets in a stream of output, e.g. a remote command's
+// stdout. A secret split across two writes is still masked, because output
+// that may be the start of a secret is held back until the next write or Flush.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+// NewWriter returns a Writer that writes the masked output to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (r *Writer) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.pending, b...)
+	mu.RLock()
+	keep := partialSecret(data)
+	mu.RUnlock()
+
+	out := data[:len(data)-keep]
+	r.pending = append([]byte(nil), data[len(data)-keep:]...)
+	if len(out) > 0 {
+		if _, err := io.WriteString(r.w, String(string(out))); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes the output held back by the last Write.
+func (r *Writer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(r.w, String(string(r.pending)))
+	r.pending = nil
+	return err
+}
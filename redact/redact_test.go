@@ -0,0 +1,127 @@
+package redact
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	Add("hunter22", "it's-secret", "abc", "")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"no secrets here", "no secrets here"},
+		{"password hunter22 used", "password [REDACTED] used"},
+		{"hunter22hunter22", "[REDACTED][REDACTED]"},
+		{"echo 'it'\\''s-secret' | chpasswd", "echo '[REDACTED]' | chpasswd"},
+		{"plain it's-secret", "plain [REDACTED]"},
+		{"short abc stays", "short abc stays"},
+		{"K10abc123::server:s3cr3t joined", "[REDACTED] joined"},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStringLongestFirst(t *testing.T) {
+	Add("overlap", "overlapping-secret")
+	if got := String("x overlapping-secret y"); got != "x [REDACTED] y" {
+		t.Errorf("got %q, want the longer secret masked as a whole", got)
+	}
+}
+
+func TestError(t *testing.T) {
+	Add("err-secret")
+
+	if Error(nil) != nil {
+		t.Error("Error(nil) is not nil")
+	}
+
+	plain := errors.New("nothing to hide")
+	if got := Error(plain); got != plain {
+		t.Errorf("Error without secrets returned %v, want the original error", got)
+	}
+
+	err := Error(errors.Join(errors.New("login with err-secret failed"), fs.ErrPermission))
+	if strings.Contains(err.Error(), "err-secret") {
+		t.Errorf("secret not masked in %q", err)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Error("masked error does not wrap the original error")
+	}
+}
+
+func TestPartialSecret(t *testing.T) {
+	Add("partial-secret")
+	mu.RLock()
+	defer mu.RUnlock()
+
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"nothing", 0},
+		{"output p", 1},
+		{"output partial-", 8},
+		{"output partial-secre", 13},
+		{"output partial-secret", 0}, // complete, masked right away
+		{"part", 4},
+	}
+	for _, tt := range tests {
+		if got := partialSecret([]byte(tt.data)); got != tt.want {
+			t.Errorf("partialSecret(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	Add("split-secret")
+
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"single write", []string{"token split-secret\n"}, "token [REDACTED]\n"},
+		{"split across writes", []string{"token spl", "it-sec", "ret\n"}, "token [REDACTED]\n"},
+		{"split at every byte", strings.Split("a split-secret b", ""), "a [REDACTED] b"},
+		{"prefix not completed", []string{"token split-", "other\n"}, "token split-other\n"},
+		{"held back until flush", []string{"ends with split-sec"}, "ends with split-sec"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		w := NewWriter(&out)
+		for _, s := range tt.writes {
+			if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+				t.Fatalf("%s: Write(%q) = %d, %v", tt.name, s, n, err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("%s: Flush: %v", tt.name, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.name, out.String(), tt.want)
+		}
+	}
+}
+
+func TestWriterHoldsBack(t *testing.T) {
+	Add("held-secret")
+
+	var out strings.Builder
+	w := NewWriter(&out)
+	w.Write([]byte("value: held-"))
+	if out.String() != "value: " {
+		t.Errorf("wrote %q before the secret was complete, want %q", out.String(), "value: ")
+	}
+	w.Write([]byte("secret"))
+	if out.String() != "value: [REDACTED]" {
+		t.Errorf("wrote %q, want %q", out.String(), "value: [REDACTED]")
+	}
+}
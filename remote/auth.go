@@ -0,0 +1,92 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+// authMethods builds the SSH authentication methods for a node in the order
+// private key, ssh-agent, password. The returned cleanup function releases
+// the agent connection and must be called once the handshake is done.
+func authMethods(node config.NodeConfig) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	cleanup := func() {}
+
+	if node.SSHKeyPath != "" {
+		signer, err := loadPrivateKey(node.SSHKeyPath, node.SSHKeyPassphrase)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if node.SSHAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, cleanup, fmt.Errorf("ssh_agent is enabled for %s but SSH_AUTH_SOCK is not set", node.IP)
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("could not connect to ssh-agent: %w", err)
+		}
+		cleanup = func() { conn.Close() }
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if node.SSHPass != "" {
+		methods = append(methods, ssh.Password(node.SSHPass))
+	}
+
+	if len(methods) == 0 {
+		return nil, cleanup, fmt.Errorf("no SSH credentials configured for %s", node.IP)
+	}
+	return methods, cleanup, nil
+}
+
+// loadPrivateKey reads and parses a private key, decrypting it with the
+// passphrase if one is given.
+func loadPrivateKey(path, passphrase string) (ssh.Signer, error) {
+	path = expandHome(path)
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read SSH key %s: %w", path, err)
+	}
+
+	if passphrase != "" {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt SSH key %s: %w", path, err)
+		}
+		return signer, nil
+	}
+
+	signer, err := ssh.ParsePrivateKey(pem)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("SSH key %s is encrypted, set ssh_key_passphrase", path)
+		}
+		return nil, fmt.Errorf("could not parse SSH key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// expandHome replaces a leading "~/" with the home directory of the current user.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,155 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+// passwordless caches per connection whether the become method works
+// without a password (NOPASSWD rule, doas nopass/persist or cached credentials).
+var passwordless sync.Map
+
+// ExecPrivileged runs a shell script as root using the node's become method
+// and streams its output. A non-zero exit status is returned as error.
+func ExecPrivileged(ctx context.Context, node config.NodeConfig, script string) error {
+	res, err := becomeExecute(ctx, node, script, true)
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// RunPrivileged runs a shell script as root using the node's become method
+// and captures its output like Run.
+func RunPrivileged(ctx context.Context, node config.NodeConfig, script string) (*Result, error) {
+	return becomeExecute(ctx, node, script, false)
+}
+
+// becomeExecute wraps the script for the node's become method. Passwords are
+// never part of the command line:
+//   - sudo reads it from the session's stdin (sudo -S),
+//   - doas and su only read from a terminal, so they get a PTY and the
+//     first password prompt is answered.
+//
+// Privileged commands never get the local terminal, otherwise the password
+// would be echoed.
+func becomeExecute(ctx context.Context, node config.NodeConfig, script string, stream bool) (*Result, error) {
+	shell := "sh -c " + Quote(script)
+	password := node.BecomePassword()
+
+	switch node.BecomeMethod() {
+	case config.BecomeNone:
+		return execute(ctx, node, shell, execOptions{stream: stream})
+
+	case config.BecomeSudo:
+		nopasswd, err := withoutPassword(ctx, node, "sudo -n true")
+		if err != nil {
+			return nil, err
+		}
+		if nopasswd {
+			return execute(ctx, node, "sudo -n -- "+shell, execOptions{stream: stream})
+		}
+		if password == "" {
+			return nil, fmt.Errorf("sudo on %s requires a password, set become_pass or ssh_pass", node.IP)
+		}
+		return execute(ctx, node, "sudo -S -p '' -- "+shell, execOptions{stdin: strings.NewReader(password + "\n"), stream: stream})
+
+	case config.BecomeDoas:
+		nopasswd, err := withoutPassword(ctx, node, "doas -n true")
+		if err != nil {
+			return nil, err
+		}
+		if nopasswd {
+			return execute(ctx, node, "doas -n -- "+shell, execOptions{stream: stream})
+		}
+		if password == "" {
+			return nil, fmt.Errorf("doas on %s requires a password, set become_pass or ssh_pass", node.IP)
+		}
+		return execute(ctx, node, "doas -- "+shell, execOptions{password: password, stream: stream})
+
+	case config.BecomeSu:
+		if node.BecomePass == "" {
+			return nil, fmt.Errorf("su on %s requires the root password in become_pass", node.IP)
+		}
+		return execute(ctx, node, "su root -c "+Quote(shell), execOptions{password: node.BecomePass, stream: stream})
+	}
+
+	return nil, fmt.Errorf("unknown become method %q for %s", node.Become, node.IP)
+}
+
+// withoutPassword checks once per connection whether the given probe
+// command succeeds without asking for a password.
+func withoutPassword(ctx context.Context, node config.NodeConfig, probe string) (bool, error) {
+	key := routeKey(route(node)) + " " + probe
+	if v, ok := passwordless.Load(key); ok {
+		return v.(bool), nil
+	}
+
+	res, err := Run(ctx, node, probe)
+	if err != nil {
+		return false, fmt.Errorf("could not run %q on %s: %w", probe, node.IP, err)
+	}
+	passwordless.Store(key, res.Success())
+	return res.Success(), nil
+}
+
+// Quote quotes a string for safe use as a single word in a POSIX shell command.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// maxPromptScan limits how much output is held back while waiting for a
+// password prompt. Commands that do not ask at all are forwarded after that.
+const maxPromptScan = 4096
+
+// passwordPrompter watches PTY output for the first password prompt,
+// answers it and removes the prompt from the output.
+type passwordPrompter struct {
+	w        io.Writer
+	stdin    io.WriteCloser
+	password string
+	pending  []byte
+	done     bool
+}
+
+func (p *passwordPrompter) Write(b []byte) (int, error) {
+	if p.done {
+		return p.w.Write(b)
+	}
+
+	p.pending = append(p.pending, b...)
+	if i := bytes.Index(bytes.ToLower(p.pending), []byte("password")); i >= 0 {
+		if j := bytes.IndexByte(p.pending[i:], ':'); j >= 0 {
+			p.done = true
+			rest := bytes.TrimLeft(p.pending[i+j+1:], " ")
+			p.pending = nil
+			if _, err := io.WriteString(p.stdin, p.password+"\n"); err != nil {
+				return 0, err
+			}
+			if _, err := p.w.Write(rest); err != nil {
+				return 0, err
+			}
+			return len(b), nil
+		}
+	}
+
+	if len(p.pending) > maxPromptScan {
+		p.flush()
+	}
+	return len(b), nil
+}
+
+// flush forwards output held back while no prompt has been seen.
+func (p *passwordPrompter) flush() {
+	p.done = true
+	if len(p.pending) > 0 {
+		p.w.Write(p.pending)
+		p.pending = nil
+	}
+}
@@ -0,0 +1,96 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/utils"
+)
+
+// settings holds the global SSH settings applied to every connection.
+var settings config.SSHConfig
+
+// Configure sets the global SSH settings (known_hosts file, host key policy,
+// bastion hosts) used by all following connections.
+func Configure(s config.SSHConfig) {
+	settings = s
+}
+
+// noticesKey is the context key of the function set by WithNotices.
+type noticesKey struct{}
+
+// WithNotices returns a context whose connections report warnings, like a
+// newly trusted host key, to notify instead of printing them. Full-screen
+// UIs use it to show the warnings without garbling the screen.
+func WithNotices(ctx context.Context, notify func(msg string)) context.Context {
+	return context.WithValue(ctx, noticesKey{}, notify)
+}
+
+// notice reports a connection warning to the function set by WithNotices,
+// or prints it.
+func notice(ctx context.Context, msg string) {
+	if notify, ok := ctx.Value(noticesKey{}).(func(string)); ok {
+		notify(msg)
+		return
+	}
+	utils.PrintSectionHeader(msg, "[WARN]", utils.ColorYellow, false)
+}
+
+// route returns the bastion hosts followed by the node itself. A node's own
+// proxy_jump wins over the global one; an explicitly empty list means direct.
+func route(node config.NodeConfig) []config.NodeConfig {
+	hops := settings.ProxyJump
+	if node.ProxyJump != nil {
+		hops = node.ProxyJump
+	}
+	r := make([]config.NodeConfig, 0, len(hops)+1)
+	r = append(r, hops...)
+	return append(r, node)
+}
+
+// dial opens an authenticated SSH connection to the given node. If via is
+// set, the TCP connection is tunnelled through that (bastion) client.
+// Cancelling ctx aborts the connect and the handshake.
+func dial(ctx context.Context, node config.NodeConfig, via *ssh.Client) (*ssh.Client, error) {
+	auth, cleanup, err := authMethods(node)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := node.Address()
+	clientConfig := &ssh.ClientConfig{
+		User:              node.SSHUser,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback(ctx, settings),
+		HostKeyAlgorithms: hostKeyAlgorithms(settings, addr),
+	}
+
+	var conn net.Conn
+	through := ""
+	if via == nil {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		through = fmt.Sprintf(" via %s", via.RemoteAddr())
+		conn, err = via.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, Cancelled(ctx, fmt.Errorf("SSH connection to %s%s failed: %w", node.IP, through, err))
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if !stop() {
+		conn.Close()
+		return nil, Cancelled(ctx, fmt.Errorf("SSH handshake with %s%s aborted", node.IP, through))
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH connection to %s%s failed: %w", node.IP, through, err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
@@ -0,0 +1,174 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+// defaultHostKeyAlgorithms are offered after the algorithms known for a host.
+var defaultHostKeyAlgorithms = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA,
+}
+
+// knownHostsMu serialises reads and writes of the known_hosts file so that
+// concurrent trust-on-first-use updates do not corrupt it.
+var knownHostsMu sync.Mutex
+
+// knownHostsPath returns the known_hosts file configured in the ssh section,
+// falling back to ~/.ssh/known_hosts.
+func knownHostsPath(s config.SSHConfig) string {
+	if s.KnownHostsFile != "" {
+		return expandHome(s.KnownHostsFile)
+	}
+	return expandHome("~/.ssh/known_hosts")
+}
+
+// hostKeyCallback verifies server host keys against the known_hosts file.
+// Unknown hosts are rejected unless the policy is "tofu", in which case their
+// key is recorded and reported as a notice on ctx. A changed key is always a
+// hard failure.
+func hostKeyCallback(ctx context.Context, s config.SSHConfig) ssh.HostKeyCallback {
+	path := knownHostsPath(s)
+	tofu := s.HostKeyPolicy == config.HostKeyPolicyTOFU
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsMu.Lock()
+		defer knownHostsMu.Unlock()
+
+		check, err := loadKnownHosts(path, tofu)
+		if err != nil {
+			return err
+		}
+
+		err = check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return hostKeyChangedError(hostname, path, key, keyErr.Want)
+		}
+
+		if !tofu {
+			return fmt.Errorf("host key of %s (%s %s) is not in %s; add it with ssh-keyscan or set ssh.host_key_policy to %q",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), path, config.HostKeyPolicyTOFU)
+		}
+
+		if err := appendKnownHost(path, hostname, key); err != nil {
+			return err
+		}
+		notice(ctx, fmt.Sprintf("Trusting new host key of %s (%s %s), saved to %s",
+			hostname, key.Type(), ssh.FingerprintSHA256(key), path))
+		return nil
+	}
+}
+
+// hostKeyAlgorithms orders the supported host key algorithms so that the ones
+// already known for a host come first. The server then presents a key we can
+// verify instead of a different type, while a real key change is still detected.
+func hostKeyAlgorithms(s config.SSHConfig, address string) []string {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	check, err := loadKnownHosts(knownHostsPath(s), false)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(address, &net.TCPAddr{}, probeKey{}), &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, known := range keyErr.Want {
+		switch t := known.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	if len(algos) == 0 {
+		return nil
+	}
+
+	for _, algo := range defaultHostKeyAlgorithms {
+		if !slices.Contains(algos, algo) {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
+
+// loadKnownHosts parses the known_hosts file. A missing file counts as empty
+// in TOFU mode and as an error otherwise.
+func loadKnownHosts(path string, tofu bool) (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if !tofu {
+			return nil, fmt.Errorf("known_hosts file %s does not exist; create it or set ssh.host_key_policy to %q", path, config.HostKeyPolicyTOFU)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("could not create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			return nil, fmt.Errorf("could not create %s: %w", path, err)
+		}
+	}
+
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read known_hosts file %s: %w", path, err)
+	}
+	return check, nil
+}
+
+// appendKnownHost records a host key in the known_hosts file.
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// hostKeyChangedError describes the difference between the recorded keys and
+// the key the server presented.
+func hostKeyChangedError(hostname, path string, key ssh.PublicKey, known []knownhosts.KnownKey) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HOST KEY OF %s HAS CHANGED - possible man-in-the-middle attack!\n", hostname)
+	for _, k := range known {
+		fmt.Fprintf(&b, "  - known:    %s %s (%s:%d)\n", k.Key.Type(), ssh.FingerprintSHA256(k.Key), k.Filename, k.Line)
+	}
+	fmt.Fprintf(&b, "  + received: %s %s\n", key.Type(), ssh.FingerprintSHA256(key))
+	fmt.Fprintf(&b, "If the change is expected, remove the old entry from %s", path)
+	return errors.New(b.String())
+}
+
+// probeKey never matches a real key. It is used to look up the keys known
+// for a host without contacting it.
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "probe" }
+func (probeKey) Marshal() []byte                     { return []byte("probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
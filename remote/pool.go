@@ -0,0 +1,268 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+// keepAliveInterval is the period between keepalive requests on idle connections.
+const keepAliveInterval = 30 * time.Second
+
+// keepAliveTimeout bounds the wait for a keepalive reply. A connection that
+// does not answer in time is considered dead.
+const keepAliveTimeout = 15 * time.Second
+
+// pool keeps one SSH connection per node for the whole run. Bastion hosts
+// are pooled as well, so all nodes behind the same bastion share its connection.
+type pool struct {
+	mu      sync.Mutex
+	clients map[string]*ssh.Client
+	// dialing holds the connections being established, so that concurrent
+	// callers of the same route wait for one dial instead of starting their own.
+	dialing map[string]*pendingDial
+}
+
+// pendingDial is a connection being established. done is closed once
+// client or err is set.
+type pendingDial struct {
+	done   chan struct{}
+	client *ssh.Client
+	err    error
+	// cancelled is set if the dial failed because the context of the
+	// dialing caller was done, which says nothing about the host.
+	cancelled bool
+}
+
+var connections = &pool{clients: map[string]*ssh.Client{}, dialing: map[string]*pendingDial{}}
+
+// routeKey identifies a connection by user and address of every hop.
+func routeKey(r []config.NodeConfig) string {
+	parts := make([]string, len(r))
+	for i, n := range r {
+		parts[i] = n.SSHUser + "@" + n.Address()
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// client returns the pooled connection of a node and dials it on first use.
+func (p *pool) client(ctx context.Context, node config.NodeConfig) (*ssh.Client, error) {
+	return p.connect(ctx, route(node))
+}
+
+// connect returns the pooled connection to the last host of the route and
+// dials it on first use. p.mu is not held during the dial, so an unreachable
+// host only delays the callers of its own route; they wait for the pending
+// dial as long as their ctx allows.
+func (p *pool) connect(ctx context.Context, r []config.NodeConfig) (*ssh.Client, error) {
+	key := routeKey(r)
+	for {
+		p.mu.Lock()
+		if c, ok := p.clients[key]; ok {
+			p.mu.Unlock()
+			return c, nil
+		}
+		d, waiting := p.dialing[key]
+		if !waiting {
+			d = &pendingDial{done: make(chan struct{})}
+			p.dialing[key] = d
+		}
+		p.mu.Unlock()
+
+		if !waiting {
+			d.client, d.err = p.establish(ctx, r)
+			d.cancelled = d.err != nil && ctx.Err() != nil
+			p.mu.Lock()
+			delete(p.dialing, key)
+			if d.err == nil {
+				p.add(key, d.client)
+			}
+			p.mu.Unlock()
+			close(d.done)
+			return d.client, d.err
+		}
+
+		select {
+		case <-d.done:
+		case <-ctx.Done():
+			return nil, Cancelled(ctx, fmt.Errorf("SSH connection to %s failed: still connecting", r[len(r)-1].IP))
+		}
+		// A dial aborted by the context of another caller is retried with ours
+		if d.cancelled && ctx.Err() == nil {
+			continue
+		}
+		return d.client, d.err
+	}
+}
+
+// establish dials the last host of the route through the (pooled)
+// connection of the previous hop. If a pooled hop turns out to be dead, it
+// is re-established once.
+func (p *pool) establish(ctx context.Context, r []config.NodeConfig) (*ssh.Client, error) {
+	target := r[len(r)-1]
+	if len(r) == 1 {
+		return dial(ctx, target, nil)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		via, err := p.connect(ctx, r[:len(r)-1])
+		if err != nil {
+			return nil, err
+		}
+		c, err := dial(ctx, target, via)
+		if err == nil {
+			return c, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		p.forget(routeKey(r[:len(r)-1]), via)
+		via.Close()
+	}
+	return nil, lastErr
+}
+
+// add stores a new connection and starts its keepalive loop. The caller must hold p.mu.
+func (p *pool) add(key string, c *ssh.Client) {
+	p.clients[key] = c
+	go p.keepAlive(key, c)
+}
+
+// drop removes a broken connection from the pool so that the next call redials.
+func (p *pool) drop(node config.NodeConfig, c *ssh.Client) {
+	key := routeKey(route(node))
+
+	p.mu.Lock()
+	if p.clients[key] == c {
+		delete(p.clients, key)
+	}
+	p.mu.Unlock()
+
+	c.Close()
+}
+
+// keepAlive sends periodic keepalive requests and drops the connection
+// once the server stops answering.
+func (p *pool) keepAlive(key string, c *ssh.Client) {
+	closed := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			p.forget(key, c)
+			return
+		case <-ticker.C:
+			if err := sendKeepAlive(c); err != nil {
+				p.forget(key, c)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// sendKeepAlive sends one keepalive request and waits at most
+// keepAliveTimeout for the reply. On a half-open connection SendRequest
+// would only fail after the TCP retransmission timeout; closing the client
+// after a timeout also ends the pending request.
+func sendKeepAlive(c *ssh.Client) error {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := c.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+	select {
+	case err := <-reply:
+		return err
+	case <-time.After(keepAliveTimeout):
+		return fmt.Errorf("no keepalive reply within %s", keepAliveTimeout)
+	}
+}
+
+// forget removes a connection from the pool without closing it.
+func (p *pool) forget(key string, c *ssh.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clients[key] == c {
+		delete(p.clients, key)
+	}
+}
+
+// closeAll closes every pooled connection.
+func (p *pool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, c := range p.clients {
+		c.Close()
+		delete(p.clients, key)
+	}
+}
+
+// NewSession opens a session on the pooled connection of a node. If the
+// connection turns out to be broken it is re-established once.
+func NewSession(ctx context.Context, node config.NodeConfig) (*ssh.Session, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		c, err := connections.client(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		session, err := c.NewSession()
+		if err == nil {
+			return session, nil
+		}
+		lastErr = err
+		connections.drop(node, c)
+	}
+	return nil, fmt.Errorf("could not open SSH session on %s: %w", node.IP, lastErr)
+}
+
+// NewSFTP opens an SFTP client on the pooled connection of a node. The caller
+// closes the SFTP client; the SSH connection stays in the pool. When ctx is
+// cancelled the SFTP client is closed, which aborts running transfers.
+func NewSFTP(ctx context.Context, node config.NodeConfig) (*sftp.Client, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		c, err := connections.client(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		sftpClient, err := sftp.NewClient(c)
+		if err == nil {
+			context.AfterFunc(ctx, func() { sftpClient.Close() })
+			return sftpClient, nil
+		}
+		lastErr = err
+		connections.drop(node, c)
+	}
+	return nil, fmt.Errorf("could not open SFTP session on %s: %w", node.IP, lastErr)
+}
+
+// Cancelled puts the cancellation cause of ctx in front of err if ctx is
+// done, so that e.g. an aborted SFTP transfer reports why it was aborted.
+func Cancelled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w (%v)", context.Cause(ctx), err)
+	}
+	return err
+}
+
+// CloseAll closes all pooled connections. It is called once at the end of a run.
+func CloseAll() {
+	connections.closeAll()
+}
@@ -1,97 +1,162 @@
 package remote
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/term"
-	"igneos.cloud/kubernetes/k3s-installer/utils"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
 )
 
-func RemoteExec(user, password, host string, command string) error {
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
+// ErrInterrupted is the cancellation cause used when the run is aborted by
+// SIGINT or SIGTERM.
+var ErrInterrupted = errors.New("interrupted")
+
+// Result is the outcome of a remote command.
+type Result struct {
+	Host     string
+	Command  string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
 
-	client, err := ssh.Dial("tcp", host+":22", config)
-	if err != nil {
-		return fmt.Errorf("SSH-Verbindung fehlgeschlagen: %v", err)
-	}
-	defer client.Close()
+// Success reports whether the command exited with status 0.
+func (r *Result) Success() bool {
+	return r.ExitCode == 0
+}
 
-	session, err := client.NewSession()
-	if err != nil {
-		return fmt.Errorf("SSH-Session konnte nicht erstellt werden: %v", err)
+// Err returns an error describing a non-zero exit status, including the
+// last line of stderr, or nil if the command succeeded.
+func (r *Result) Err() error {
+	if r.Success() {
+		return nil
 	}
-	defer session.Close()
+	msg := fmt.Sprintf("Remote-Command on %s failed with exit code %d", r.Host, r.ExitCode)
+	if stderr := strings.TrimSpace(r.Stderr); stderr != "" {
+		lines := strings.Split(stderr, "\n")
+		msg += ": " + lines[len(lines)-1]
+	}
+	return errors.New(redact.String(msg))
+}
 
-	// Leite Stdout/Stderr durch
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
-	session.Stdin = os.Stdin
+// Run executes a command without a PTY and captures stdout and stderr
+// separately. The returned error is only set if the command could not be
+// run; a non-zero exit status is reported in Result.ExitCode.
+func Run(ctx context.Context, node config.NodeConfig, command string) (*Result, error) {
+	return execute(ctx, node, command, execOptions{})
+}
 
-	// Aktiviere ein Pseudo-Terminal
-	fd := int(os.Stdin.Fd())
-	oldState, err := term.MakeRaw(fd)
-	if err != nil {
-		return fmt.Errorf("Fehler beim Setzen des Terminalzustands: %v", err)
-	}
-	defer term.Restore(fd, oldState)
+// execOptions controls how execute wires up a session.
+type execOptions struct {
+	// stdin feeds the remote process.
+	stdin io.Reader
+	// stream copies the output to the local terminal as well.
+	stream bool
+	// password requests a PTY without the local terminal and answers the
+	// first password prompt of the remote process (su, doas) with it.
+	password string
+}
 
-	// Fange Signale ab und stelle den Terminalzustand wieder her
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigs
-		term.Restore(fd, oldState)
-		os.Exit(0)
-	}()
+// execute runs the command on a pooled session and collects the result.
+// If ctx is cancelled, the remote process is interrupted and the
+// cancellation cause is returned.
+func execute(ctx context.Context, node config.NodeConfig, command string, opts execOptions) (res *Result, err error) {
+	addSecrets(node)
+	start := time.Now()
+	defer func() { logCommand(node, command, start, res, err) }()
 
-	modes := ssh.TerminalModes{
-		ssh.ECHO:          1,     // Enable echo
-		ssh.TTY_OP_ISPEED: 14400, // input speed = 14.4kbaud
-		ssh.TTY_OP_OSPEED: 14400, // output speed = 14.4kbaud
+	session, err := NewSession(ctx, node)
+	if err != nil {
+		return nil, fmt.Errorf("SSH-Session konnte nicht erstellt werden: %w", err)
 	}
+	defer session.Close()
 
-	if err := session.RequestPty("xterm", 80, 40, modes); err != nil {
-		return fmt.Errorf("PTY konnte nicht angefordert werden: %v", err)
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	session.Stdin = opts.stdin
+	if opts.stream {
+		// Leite Stdout/Stderr durch, ohne bekannte Secrets
+		consoleOut, consoleErr := redact.NewWriter(os.Stdout), redact.NewWriter(os.Stderr)
+		defer consoleOut.Flush()
+		defer consoleErr.Flush()
+		session.Stdout = io.MultiWriter(consoleOut, &stdout)
+		session.Stderr = io.MultiWriter(consoleErr, &stderr)
 	}
 
-	//  utils.PrintSectionHeader("", "[SSH]", utils.ColorBlue, false, 40)
-	if err := session.Run(command); err != nil {
-		return fmt.Errorf("Remote-Command is fail: %v", err)
+	var prompter *passwordPrompter
+	if opts.password != "" {
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("could not open stdin of session: %w", err)
+		}
+		prompter = &passwordPrompter{w: session.Stdout, stdin: stdin, password: opts.password}
+		session.Stdout = prompter
+
+		// No echo so the password does not show up in the output, no CR/LF translation
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          0,
+			ssh.ONLCR:         0,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty("dumb", 80, 200, modes); err != nil {
+			return nil, fmt.Errorf("PTY konnte nicht angefordert werden: %v", err)
+		}
 	}
 
-	utils.PrintSectionHeader(fmt.Sprintf("%s: Command completed successfully\n", host), "[SSH]", utils.ColorBlue, false)
-	return nil
-}
-
-func RemoteExecOutput(user, password, host, command string) (string, error) {
-	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	start = time.Now()
+	err = run(ctx, session, command)
+	if prompter != nil {
+		prompter.flush()
+	}
+	res = &Result{
+		Host:     node.IP,
+		Command:  command,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
 	}
 
-	client, err := ssh.Dial("tcp", host+":22", config)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitStatus()
+		return res, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("SSH-Connect is fail: %v", err)
+		return res, fmt.Errorf("Remote-Command on %s failed: %w", node.IP, err)
 	}
-	defer client.Close()
+	return res, nil
+}
 
-	session, err := client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("Session konnte nicht erstellt werden: %v", err)
+// run executes the command and waits for it. When ctx is cancelled (Ctrl-C,
+// step timeout) an interrupt is forwarded to the remote process, the session
+// is closed and the cancellation cause is returned.
+func run(ctx context.Context, session *ssh.Session, command string) error {
+	if err := session.Start(command); err != nil {
+		return err
 	}
-	defer session.Close()
 
-	output, err := session.CombinedOutput(command)
-	return string(output), err
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		session.Signal(ssh.SIGINT)
+		session.Close()
+		return context.Cause(ctx)
+	}
 }
@@ -0,0 +1,97 @@
+package remote
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/sftp"
+	"igneos.cloud/kubernetes/k3s-installer/config"
+)
+
+// ExecScript uploads a shell script, runs it as root using the node's become
+// method and streams its output. A non-zero exit status is returned as error.
+func ExecScript(ctx context.Context, node config.NodeConfig, name, script string) error {
+	res, err := runScript(ctx, node, name, script)
+	if err != nil {
+		return fmt.Errorf("script %s: %w", name, err)
+	}
+	if err := res.Err(); err != nil {
+		return fmt.Errorf("script %s: %w", name, err)
+	}
+	return nil
+}
+
+// cleanupTimeout bounds the removal of the script directory after the
+// script was cancelled.
+const cleanupTimeout = 30 * time.Second
+
+// runScript writes the script via SFTP into a fresh directory below /tmp that
+// only the SSH user can access (0700), runs it with sh and removes the
+// directory afterwards. The script content never passes through shell
+// quoting, so it may contain any characters.
+func runScript(ctx context.Context, node config.NodeConfig, name, script string) (*Result, error) {
+	sftpClient, err := NewSFTP(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	defer sftpClient.Close()
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("could not create script name: %w", err)
+	}
+	dir := "/tmp/k3s-installer-" + hex.EncodeToString(suffix)
+	file := path.Join(dir, name+".sh")
+
+	if err := sftpClient.Mkdir(dir); err != nil {
+		return nil, fmt.Errorf("could not create %s on %s: %w", dir, node.IP, err)
+	}
+	defer removeScriptDir(ctx, node, sftpClient, dir)
+
+	if err := sftpClient.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not protect %s on %s: %w", dir, node.IP, err)
+	}
+
+	f, err := sftpClient.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s on %s: %w", file, node.IP, err)
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not protect %s on %s: %w", file, node.IP, err)
+	}
+	if _, err := f.Write([]byte(script)); err != nil {
+		f.Close()
+		return nil, Cancelled(ctx, fmt.Errorf("could not upload %s to %s: %w", file, node.IP, err))
+	}
+	if err := f.Close(); err != nil {
+		return nil, Cancelled(ctx, fmt.Errorf("could not upload %s to %s: %w", file, node.IP, err))
+	}
+
+	addSecrets(node)
+	logScript(node, file, script)
+	return becomeExecute(ctx, node, "sh "+Quote(file), true)
+}
+
+// removeScriptDir deletes the script directory. If ctx was cancelled, the
+// SFTP client is already closed and a fresh one is used.
+func removeScriptDir(ctx context.Context, node config.NodeConfig, sftpClient *sftp.Client, dir string) {
+	if ctx.Err() == nil {
+		sftpClient.RemoveAll(dir)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+	c, err := NewSFTP(ctx, node)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	c.RemoveAll(dir)
+}
@@ -0,0 +1,143 @@
+package remote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"igneos.cloud/kubernetes/k3s-installer/config"
+	"igneos.cloud/kubernetes/k3s-installer/redact"
+	"igneos.cloud/kubernetes/k3s-installer/utils"
+)
+
+// transcriptLog writes one log file per host into the run directory. Every
+// command is recorded with its script, output, exit code and timestamps;
+// known secrets are masked.
+type transcriptLog struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]*os.File
+	err   error
+}
+
+var transcripts = &transcriptLog{files: map[string]*os.File{}}
+
+// unsafeFileChars are replaced in host names used as file names (IPv6 colons, brackets).
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// SetTranscriptDir enables transcripts in a new run directory
+// <base>/<timestamp>. The directory is created on the first command.
+// An empty base disables transcripts.
+func SetTranscriptDir(base string) {
+	transcripts.mu.Lock()
+	defer transcripts.mu.Unlock()
+	if base == "" {
+		transcripts.dir = ""
+		return
+	}
+	transcripts.dir = filepath.Join(base, time.Now().Format("20060102-150405"))
+}
+
+// TranscriptDir returns the run directory, or "" if no transcript was written.
+func TranscriptDir() string {
+	transcripts.mu.Lock()
+	defer transcripts.mu.Unlock()
+	if len(transcripts.files) == 0 {
+		return ""
+	}
+	return transcripts.dir
+}
+
+// CloseTranscripts closes all transcript files. It is called once at the end of a run.
+func CloseTranscripts() {
+	transcripts.mu.Lock()
+	defer transcripts.mu.Unlock()
+	for host, f := range transcripts.files {
+		f.Close()
+		delete(transcripts.files, host)
+	}
+}
+
+// write appends a redacted entry to the transcript of a host. A failure
+// to write is reported once and disables further transcripts.
+func (t *transcriptLog) write(host, entry string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dir == "" || t.err != nil {
+		return
+	}
+
+	f, ok := t.files[host]
+	if !ok {
+		f, t.err = t.open(host)
+		if t.err != nil {
+			utils.PrintSectionHeader(fmt.Sprintf("Transcripts disabled: %v", t.err), "[WARN]", utils.ColorYellow, false)
+			return
+		}
+		t.files[host] = f
+	}
+	f.WriteString(redact.String(entry))
+}
+
+// open creates the transcript file of a host, readable by the owner only.
+func (t *transcriptLog) open(host string) (*os.File, error) {
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", t.dir, err)
+	}
+	name := filepath.Join(t.dir, unsafeFileChars.ReplaceAllString(host, "_")+".log")
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+}
+
+// addSecrets registers the credentials of a node for redaction, in case
+// they were not registered with the rest of the configuration.
+func addSecrets(node config.NodeConfig) {
+	redact.Add(node.SSHPass, node.BecomePass, node.SSHKeyPassphrase)
+}
+
+// logScript records the content of an uploaded script.
+func logScript(node config.NodeConfig, file, script string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s script %s\n", timestamp(time.Now()), file)
+	b.WriteString(script)
+	if !strings.HasSuffix(script, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	transcripts.write(node.IP, b.String())
+}
+
+// logCommand records a finished command with its output and exit status.
+// res is nil if the command could not be started.
+func logCommand(node config.NodeConfig, command string, start time.Time, res *Result, err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s exec\n$ %s\n", timestamp(start), command)
+	if res != nil {
+		section(&b, "stdout", res.Stdout)
+		section(&b, "stderr", res.Stderr)
+		fmt.Fprintf(&b, "--- exit code %d, finished %s, took %s\n", res.ExitCode, timestamp(start.Add(res.Duration)), res.Duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "--- error: %v\n", err)
+	}
+	b.WriteString("\n")
+	transcripts.write(node.IP, b.String())
+}
+
+// section writes a labelled block of output, skipping empty output.
+func section(b *strings.Builder, label, output string) {
+	if output == "" {
+		return
+	}
+	fmt.Fprintf(b, "--- %s\n%s", label, output)
+	if !strings.HasSuffix(output, "\n") {
+		b.WriteString("\n")
+	}
+}
+
+func timestamp(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,175 @@
+// Package retry runs operations again after transient failures, waiting
+// with exponential backoff and jitter between the attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Policy describes how often and how fast an operation is retried.
+type Policy struct {
+	// Attempts is the maximum number of attempts including the first one.
+	Attempts int
+	// InitialDelay is the wait before the second attempt.
+	InitialDelay time.Duration
+	// MaxDelay caps the wait between two attempts.
+	MaxDelay time.Duration
+	// Multiplier grows the wait after every attempt.
+	Multiplier float64
+	// Jitter randomizes every wait by up to ±Jitter (0.2 = ±20%).
+	Jitter float64
+}
+
+// Default is the policy for operations without a policy of their own: a
+// single attempt, i.e. no retries.
+var Default = Policy{Attempts: 1, InitialDelay: 2 * time.Second, MaxDelay: 30 * time.Second, Multiplier: 2, Jitter: 0.2}
+
+// Do calls op until it succeeds, fails permanently, the attempts are used up
+// or ctx is done. Before every retry, onRetry (if not nil) is told about the
+// failed attempt and the wait. The last error is returned.
+func (p Policy) Do(ctx context.Context, op func(ctx context.Context) error, onRetry func(attempt int, err error, wait time.Duration)) error {
+	attempts := max(p.Attempts, 1)
+	delay := p.InitialDelay
+
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts || ctx.Err() != nil || !IsTransient(err) {
+			return unwrapMarker(err)
+		}
+
+		wait := p.jittered(delay)
+		if onRetry != nil {
+			onRetry(attempt, unwrapMarker(err), wait)
+		}
+		select {
+		case <-ctx.Done():
+			return unwrapMarker(err)
+		case <-time.After(wait):
+		}
+
+		delay = time.Duration(float64(delay) * max(p.Multiplier, 1))
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// jittered randomizes d by up to ±Jitter.
+func (p Policy) jittered(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	factor := 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(d) * factor)
+}
+
+// marked tags an error as transient or permanent, overriding the
+// classification by IsTransient.
+type marked struct {
+	err       error
+	transient bool
+}
+
+func (m *marked) Error() string { return m.err.Error() }
+func (m *marked) Unwrap() error { return m.err }
+
+// Transient marks err as worth retrying, e.g. a file that does not exist yet.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &marked{err: err, transient: true}
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &marked{err: err, transient: false}
+}
+
+// unwrapMarker removes an outermost Transient/Permanent marker.
+func unwrapMarker(err error) error {
+	if m, ok := err.(*marked); ok {
+		return m.err
+	}
+	return err
+}
+
+// transientMessages are error texts of SSH and kubectl failures that usually
+// go away by themselves: unreachable hosts and an API server or webhook
+// that is not ready yet.
+var transientMessages = []string{
+	"connection refused",
+	"connection reset by peer",
+	"no route to host",
+	"network is unreachable",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"Unable to connect to the server",
+	"was refused - did you specify the right host or port",
+	"ServiceUnavailable",
+	"the server is currently unable to handle the request",
+	"etcdserver: request timed out",
+	"etcdserver: leader changed",
+	"failed calling webhook",
+	"no endpoints available",
+	"ssh: handshake failed: EOF",
+}
+
+// permanentMessages win over transientMessages, e.g. a rejected login or a
+// changed host key will not fix itself.
+var permanentMessages = []string{
+	"unable to authenticate",
+	"host key",
+	"knownhosts",
+}
+
+// IsTransient reports whether err is worth retrying. Errors marked with
+// Transient or Permanent are classified accordingly; otherwise network
+// errors and the known messages of an unavailable API server are transient
+// and everything else, including cancellation, is permanent.
+func IsTransient(err error) bool {
+	var m *marked
+	if errors.As(err, &m) {
+		return m.transient
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	msg := err.Error()
+	for _, s := range permanentMessages {
+		if strings.Contains(msg, s) {
+			return false
+		}
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	for _, s := range transientMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fast retries without noticeable waits.
+var fast = Policy{Attempts: 4, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, Multiplier: 2}
+
+func TestDo(t *testing.T) {
+	transient := errors.New("dial tcp 10.0.0.1:22: connection refused")
+	permanent := errors.New("exit code 1")
+	// a step that hit its own timeout while fn reported a transient error
+	stepTimeout := fmt.Errorf("step apply-yaml: %w: %v", errors.New("timed out after 2m0s"), transient)
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		errs      []error // results of the attempts, nil means success
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", fast, []error{nil}, 1, nil},
+		{"transient then success", fast, []error{transient, transient, nil}, 3, nil},
+		{"attempts used up", fast, []error{transient, transient, transient, transient, nil}, 4, transient},
+		{"permanent", fast, []error{permanent, nil}, 1, permanent},
+		{"marked transient", fast, []error{Transient(permanent), nil}, 2, nil},
+		{"marked permanent", fast, []error{Permanent(transient), nil}, 1, transient},
+		{"default is one attempt", Policy{}, []error{transient, nil}, 1, transient},
+		{"timed out step", fast, []error{Permanent(stepTimeout), nil}, 1, stepTimeout},
+	}
+	for _, tt := range tests {
+		calls := 0
+		retries := 0
+		err := tt.policy.Do(context.Background(), func(context.Context) error {
+			calls++
+			return tt.errs[calls-1]
+		}, func(attempt int, err error, wait time.Duration) {
+			retries++
+			if attempt != calls {
+				t.Errorf("%s: onRetry got attempt %d after %d calls", tt.name, attempt, calls)
+			}
+		})
+		if calls != tt.wantCalls {
+			t.Errorf("%s: %d calls, want %d", tt.name, calls, tt.wantCalls)
+		}
+		if retries != calls-1 {
+			t.Errorf("%s: %d retries for %d calls", tt.name, retries, calls)
+		}
+		if err != tt.wantErr {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDoBackoff(t *testing.T) {
+	p := Policy{Attempts: 5, InitialDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, Multiplier: 2}
+	var waits []time.Duration
+	p.Do(context.Background(), func(context.Context) error {
+		return Transient(errors.New("not ready"))
+	}, func(attempt int, err error, wait time.Duration) {
+		waits = append(waits, wait)
+	})
+
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 4 * time.Millisecond}
+	if fmt.Sprint(waits) != fmt.Sprint(want) {
+		t.Errorf("waits %v, want %v", waits, want)
+	}
+}
+
+func TestJittered(t *testing.T) {
+	p := Policy{InitialDelay: time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		if d := p.jittered(time.Second); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("jittered(1s) = %s, want within ±20%%", d)
+		}
+	}
+}
+
+func TestDoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{Attempts: 5, InitialDelay: time.Hour}
+	calls := 0
+	err := p.Do(ctx, func(context.Context) error {
+		calls++
+		return Transient(errors.New("not ready"))
+	}, func(int, error, time.Duration) { cancel() })
+
+	if calls != 1 || err == nil || err.Error() != "not ready" {
+		t.Errorf("got %d calls and %v, want 1 call and the error of the attempt", calls, err)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("exit code 1"), false},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{fmt.Errorf("read: %w", io.EOF), true},
+		{errors.New("dial tcp 10.0.0.1:22: connect: no route to host"), true},
+		{errors.New("Unable to connect to the server: dial tcp 10.0.0.1:6443: i/o timeout"), true},
+		{errors.New("The connection to the server 127.0.0.1:6443 was refused - did you specify the right host or port?"), true},
+		{errors.New("Error from server (InternalError): failed calling webhook \"webhook.cert-manager.io\""), true},
+		{errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password]"), false},
+		{errors.New("ssh: handshake failed: knownhosts: key mismatch"), false},
+		{fmt.Errorf("step: %w", context.Canceled), false},
+		{fmt.Errorf("step: %w", context.DeadlineExceeded), false},
+		{Transient(errors.New("node-token is empty")), true},
+		{Permanent(fmt.Errorf("step facts: %w: %v", errors.New("timed out after 2m0s"), "dial tcp 10.0.0.1:22: i/o timeout")), false},
+		{fmt.Errorf("wrapped: %w", Permanent(syscall.ECONNREFUSED)), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"strings"
+
+	"igneos.cloud/kubernetes/k3s-installer/redact"
 )
 
 const (
@@ -18,8 +20,10 @@ const (
 // prefix: the prefix tag (e.g. "[INFO]" or "[OK]").
 // Color:  the ANSI Color code to apply to the prefix.
 // sepLen: how many '-' characters to use for the separator.
+// Known secrets in msg are masked.
 func PrintSectionHeader(msg, prefix, Color string, header bool) {
 	separator := strings.Repeat("-", 100)
+	msg = redact.String(msg)
 
 	if header == true {
 		fmt.Println("")        // blank line for readability
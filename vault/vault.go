@@ -0,0 +1,309 @@
+// Package vault encrypts config values and local artifacts (node token,
+// kubeconfig) with age, using a local X25519 key or a passphrase.
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"golang.org/x/term"
+)
+
+// Prefix marks an encrypted config value: "enc:" followed by the base64
+// encoded age ciphertext.
+const Prefix = "enc:"
+
+// Environment variables selecting the key.
+const (
+	KeyFileEnv    = "K3S_INSTALLER_AGE_KEY_FILE"
+	PassphraseEnv = "K3S_INSTALLER_PASSPHRASE"
+)
+
+// ErrNoKey is returned when something has to be encrypted or decrypted but
+// no key file exists, no passphrase is set and there is no terminal to ask.
+var ErrNoKey = errors.New("no age key found, run \"vault keygen\" or set " + PassphraseEnv)
+
+var (
+	mu         sync.Mutex
+	keyFile    string
+	identities []age.Identity
+	recipients []age.Recipient
+)
+
+// SetKeyFile selects the age identity file, overriding K3S_INSTALLER_AGE_KEY_FILE.
+func SetKeyFile(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	keyFile = path
+	identities, recipients = nil, nil
+}
+
+// KeyFile returns the age identity file: the one set by SetKeyFile,
+// K3S_INSTALLER_AGE_KEY_FILE or ~/.config/k3s-installer/age.key, in that order.
+func KeyFile() string {
+	mu.Lock()
+	defer mu.Unlock()
+	path, _ := keyFilePath()
+	return path
+}
+
+// keyFilePath returns the identity file and whether it was chosen
+// explicitly. The caller holds mu.
+func keyFilePath() (string, bool) {
+	if keyFile != "" {
+		return keyFile, true
+	}
+	if env := os.Getenv(KeyFileEnv); env != "" {
+		return env, true
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(dir, "k3s-installer", "age.key"), false
+}
+
+// keys loads the key on first use. An explicitly chosen key file comes
+// first, then the passphrase from K3S_INSTALLER_PASSPHRASE, then the default
+// key file and finally a passphrase read from the terminal. confirm asks for
+// a new passphrase twice.
+func keys(confirm bool) ([]age.Identity, []age.Recipient, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if identities != nil {
+		return identities, recipients, nil
+	}
+
+	path, explicit := keyFilePath()
+	if explicit {
+		return loadKeyFile(path)
+	}
+	if pass, ok := os.LookupEnv(PassphraseEnv); ok && pass != "" {
+		return usePassphrase(pass)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return loadKeyFile(path)
+	}
+
+	pass, err := promptPassphrase(confirm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return usePassphrase(pass)
+}
+
+// loadKeyFile reads X25519 identities from an age key file. The caller holds mu.
+func loadKeyFile(path string) ([]age.Identity, []age.Recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open age key: %w", err)
+	}
+	defer f.Close()
+
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read age key %s: %w", path, err)
+	}
+	var recs []age.Recipient
+	for _, id := range ids {
+		if x, ok := id.(*age.X25519Identity); ok {
+			recs = append(recs, x.Recipient())
+		}
+	}
+	if len(recs) == 0 {
+		return nil, nil, fmt.Errorf("age key %s contains no X25519 identity", path)
+	}
+	identities, recipients = ids, recs
+	return identities, recipients, nil
+}
+
+// usePassphrase derives the key from a passphrase with scrypt. The caller holds mu.
+func usePassphrase(pass string) ([]age.Identity, []age.Recipient, error) {
+	id, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		return nil, nil, err
+	}
+	identities, recipients = []age.Identity{id}, []age.Recipient{rec}
+	return identities, recipients, nil
+}
+
+// promptPassphrase reads the passphrase from the terminal without echo.
+func promptPassphrase(confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrNoKey
+	}
+
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		pass, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(pass), err
+	}
+	pass, err := read("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if pass == "" {
+		return "", errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := read("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != pass {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return pass, nil
+}
+
+// GenerateKey writes a new X25519 identity to path, readable by the owner
+// only, and returns its public key. An existing file is not overwritten.
+func GenerateKey(path string) (string, error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(f, "# public key: %s\n%s\n", id.Recipient(), id)
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return id.Recipient().String(), nil
+}
+
+// Encrypt encrypts data into an ASCII armored age file.
+func Encrypt(data []byte) ([]byte, error) {
+	_, recs, err := keys(true)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	a := armor.NewWriter(&buf)
+	if err := encryptTo(a, data, recs); err != nil {
+		return nil, err
+	}
+	if err := a.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts an age file, armored or binary.
+func Decrypt(data []byte) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(src)
+	}
+	return decryptFrom(src)
+}
+
+// IsEncrypted reports whether data is an age file.
+func IsEncrypted(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte(armor.Header)) || bytes.HasPrefix(data, []byte("age-encryption.org/"))
+}
+
+// EncryptValue encrypts a config value into "enc:<base64>".
+func EncryptValue(value string) (string, error) {
+	_, recs, err := keys(true)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := encryptTo(&buf, []byte(value), recs); err != nil {
+		return "", err
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecryptValue decrypts a value created by EncryptValue.
+func DecryptValue(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return "", fmt.Errorf("value does not start with %q", Prefix)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	plain, err := decryptFrom(bytes.NewReader(data))
+	return string(plain), err
+}
+
+// IsEncryptedValue reports whether a config value is encrypted.
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// ReadFile reads a file and decrypts it if it is an age file, so encrypted
+// and plaintext artifacts can be read alike.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !IsEncrypted(data) {
+		return data, err
+	}
+	plain, err := Decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s: %w", path, err)
+	}
+	return plain, nil
+}
+
+// WriteFile writes data readable by the owner only, encrypted if encrypt is set.
+func WriteFile(path string, data []byte, encrypt bool) error {
+	if encrypt {
+		var err error
+		if data, err = Encrypt(data); err != nil {
+			return fmt.Errorf("could not encrypt %s: %w", path, err)
+		}
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func encryptTo(dst io.Writer, data []byte, recs []age.Recipient) error {
+	w, err := age.Encrypt(dst, recs...)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func decryptFrom(src io.Reader) ([]byte, error) {
+	ids, _, err := keys(false)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(src, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}